@@ -0,0 +1,168 @@
+package main
+
+import (
+	"hash/fnv"
+	"strconv"
+	"vhost-scout/include/file_utils"
+	"vhost-scout/include/input_utils"
+	"vhost-scout/include/random_utils"
+)
+
+// banner_preview_size is the number of targets and candidates listed in the banner.
+const banner_preview_size = 10
+
+// t_wordlist is what run learns about the vhosts list in one streaming pass. The candidates themselves are read again
+// for every target and probe mode / injection vector combination, so the list is never held in memory.
+type t_wordlist struct {
+	path     string
+	count    int
+	digest   string                   // Hash of the candidates in order, a resumed scan must see the same list
+	preview  []string                 // First candidates, listed in the banner
+	suffixes *t_wildcard_suffixes     // Wildcard suffixes of the candidates
+	filter   *input_utils.Line_filter // What was dropped from the list
+}
+
+// scan_wordlist reads the vhosts list once to count its candidates, hash them and collect their wildcard suffixes.
+func scan_wordlist(path string) (t_wordlist, error) {
+
+	wordlist := t_wordlist{path: path, suffixes: new_wildcard_suffixes(), filter: input_utils.New_vhost_filter()}
+	candidates_hash := fnv.New64a()
+	preview, read_err := preview_list(path, wordlist.filter, func(line string) {
+		candidates_hash.Write([]byte(line + "\n"))
+		wordlist.suffixes.add(line)
+	})
+	wordlist.preview = preview
+	wordlist.count = wordlist.filter.Kept
+	wordlist.digest = strconv.FormatUint(candidates_hash.Sum64(), 16)
+	return wordlist, read_err
+}
+
+// preview_list reads a targets or vhosts list once through line_filter, calling visit with every line it keeps, and
+// returns the first lines it kept.
+func preview_list(path string, line_filter *input_utils.Line_filter, visit func(line string)) ([]string, error) {
+
+	line_stream, open_err := file_utils.Open_line_stream(path)
+	if open_err != nil {
+		return nil, open_err
+	}
+	defer line_stream.Close()
+
+	var preview []string
+	next_line := filtered_lines(line_stream, line_filter)
+	for line, has_line := next_line(); has_line; line, has_line = next_line() {
+		if len(preview) < banner_preview_size {
+			preview = append(preview, line)
+		}
+		if visit != nil {
+			visit(line)
+		}
+	}
+	return preview, line_stream.Err()
+}
+
+// filtered_lines returns a function handing out the lines of line_stream that line_filter keeps, normalized.
+func filtered_lines(line_stream *file_utils.Line_stream, line_filter *input_utils.Line_filter) func() (string, bool) {
+	return func() (string, bool) {
+		for line, has_line := line_stream.Next(); has_line; line, has_line = line_stream.Next() {
+			if normalized_line, keep := line_filter.Filter(line); keep {
+				return normalized_line, true
+			}
+		}
+		return "", false
+	}
+}
+
+// t_candidate is a vhost to probe, index is its position in the target's shuffled candidate stream.
+type t_candidate struct {
+	index  int
+	vhost  string
+	source string // Where the candidate came from (wordlist, certificate)
+}
+
+// t_candidate_stream reads the candidates of a target lazily: the wordlist followed by the names harvested from the
+// target's certificate that are not in it, shuffled in a window of bounded size.
+type t_candidate_stream struct {
+	lines      *file_utils.Line_stream
+	shuffle    *random_utils.Window_shuffle[t_candidate]
+	next_index int
+}
+
+// open_candidates opens the candidate stream of a target. The same wordlist, certificate names and seed always give
+// the same order, which is what lets a resumed scan skip the candidates it already probed.
+func open_candidates(wordlist_path string, certificate_vhosts []string, seed int64, shuffle_window int) (*t_candidate_stream, error) {
+
+	lines, open_err := file_utils.Open_line_stream(wordlist_path)
+	if open_err != nil {
+		return nil, open_err
+	}
+
+	// ----| Harvested names are only added when the wordlist does not have them
+	certificate_sources := map[string]string{}
+	for _, certificate_vhost := range certificate_vhosts {
+		certificate_sources[certificate_vhost] = vhost_source_certificate
+	}
+	next_certificate_vhost := 0
+	next_vhost := filtered_lines(lines, input_utils.New_vhost_filter())
+	next_unshuffled := func() (t_candidate, bool) {
+		if vhost, has_vhost := next_vhost(); has_vhost {
+			if _, is_certificate_vhost := certificate_sources[vhost]; is_certificate_vhost {
+				certificate_sources[vhost] = vhost_source_wordlist + "," + vhost_source_certificate
+				return t_candidate{vhost: vhost, source: vhost_source_wordlist + "," + vhost_source_certificate}, true
+			}
+			return t_candidate{vhost: vhost, source: vhost_source_wordlist}, true
+		}
+		for next_certificate_vhost < len(certificate_vhosts) {
+			certificate_vhost := certificate_vhosts[next_certificate_vhost]
+			next_certificate_vhost++
+			if certificate_sources[certificate_vhost] == vhost_source_certificate {
+				return t_candidate{vhost: certificate_vhost, source: vhost_source_certificate}, true
+			}
+		}
+		return t_candidate{}, false
+	}
+
+	return &t_candidate_stream{
+		lines:   lines,
+		shuffle: random_utils.New_window_shuffle(next_unshuffled, shuffle_window, seed),
+	}, nil
+}
+
+// next returns the next candidate, false once the stream is exhausted or failed (see err).
+func (candidate_stream *t_candidate_stream) next() (t_candidate, bool) {
+	candidate, has_candidate := candidate_stream.shuffle.Next()
+	if !has_candidate {
+		return t_candidate{}, false
+	}
+	candidate.index = candidate_stream.next_index
+	candidate_stream.next_index++
+	return candidate, true
+}
+
+// skip discards the first count candidates.
+func (candidate_stream *t_candidate_stream) skip(count int) {
+	for candidate_stream.next_index < count {
+		if _, has_candidate := candidate_stream.next(); !has_candidate {
+			return
+		}
+	}
+}
+
+func (candidate_stream *t_candidate_stream) err() error {
+	return candidate_stream.lines.Err()
+}
+
+func (candidate_stream *t_candidate_stream) close() {
+	candidate_stream.lines.Close()
+}
+
+// candidates_from_slice returns a function handing out candidates one at a time, the way t_candidate_stream does.
+func candidates_from_slice(candidates []t_candidate) func() (t_candidate, bool) {
+	return func() (t_candidate, bool) {
+		if len(candidates) == 0 {
+			return t_candidate{}, false
+		}
+		candidate := candidates[0]
+		candidates = candidates[1:]
+		return candidate, true
+	}
+}
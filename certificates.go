@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+	"vhost-scout/include/request_utils"
+	"vhost-scout/include/sqlite_utils"
+	"vhost-scout/include/tls_utils"
+)
+
+// ----| Where a candidate vhost came from
+const (
+	vhost_source_wordlist    = "wordlist"
+	vhost_source_certificate = "certificate"
+)
+
+// harvest_certificate_vhosts grabs the certificate of an https target, stores it and the names it lists in the
+// database and returns those names as extra candidates for the target. The handshake is paced like a probe.
+func harvest_certificate_vhosts(ctx context.Context, target string, probe_config request_utils.Probe_config, console io.Writer) ([]string, error) {
+
+	// ----| Grab certificate
+	target_url, url_parse_err := url.Parse(target)
+	if url_parse_err != nil {
+		return nil, errors.New("An error occurred while parsing target: " + target + " || Error: " + url_parse_err.Error())
+	}
+	release, pace_err := request_utils.Pace_request(ctx, probe_config, target_url.Hostname())
+	if pace_err != nil {
+		return nil, pace_err
+	}
+	certificate_info, harvest_err := tls_utils.Harvest_certificate(ctx, target, probe_config.Client)
+	release()
+	if harvest_err != nil {
+		return nil, harvest_err
+	}
+	candidate_names := certificate_info.Candidate_names()
+	fmt.Fprintf(console, "  > Certificate: %s (Issuer: %s, Expires: %s, SHA256: %s)\n", certificate_info.Subject_cn, certificate_info.Issuer, certificate_info.Not_after.Format("2006-01-02"), certificate_info.Sha256_fingerprint)
+	fmt.Fprintf(console, "  > Harvested %d name(s) from certificate: %s\n\n", len(candidate_names), strings.Join(candidate_names, ", "))
+
+	// ----| Open database interface, targets scanned in parallel take turns writing to the database
+	database_mutex.Lock()
+	defer database_mutex.Unlock()
+	database_interface, open_db_interface_err := sqlite_utils.Open_database_interface("db.sqlite")
+	if open_db_interface_err != nil {
+		return nil, errors.New("An error occurred while initializing the database interface || Error: " + open_db_interface_err.Error())
+	}
+
+	// ----| Store certificate metadata and harvested names
+	add_certificate_err := sqlite_utils.AddCertificateRowToTable(database_interface, sqlite_utils.Certificate_row{
+		Target:             target,
+		Subject_cn:         certificate_info.Subject_cn,
+		Dns_names:          strings.Join(certificate_info.Dns_names, ","),
+		Issuer:             certificate_info.Issuer,
+		Not_before:         certificate_info.Not_before.Format(time.RFC3339),
+		Not_after:          certificate_info.Not_after.Format(time.RFC3339),
+		Sha256_fingerprint: certificate_info.Sha256_fingerprint,
+	})
+	if add_certificate_err != nil {
+		sqlite_utils.Close_database_interface(database_interface)
+		return nil, errors.New("An error occurred while adding certificate to certificates db table || Error: " + add_certificate_err.Error())
+	}
+
+	for _, candidate_name := range candidate_names {
+		add_harvested_vhost_err := sqlite_utils.AddHarvestedVhostRowToTable(database_interface, sqlite_utils.Harvested_vhost_row{
+			Target:             target,
+			Vhost:              candidate_name,
+			Source:             vhost_source_certificate,
+			Sha256_fingerprint: certificate_info.Sha256_fingerprint,
+		})
+		if add_harvested_vhost_err != nil {
+			sqlite_utils.Close_database_interface(database_interface)
+			return nil, errors.New("An error occurred while adding row to harvested vhosts db table || Error: " + add_harvested_vhost_err.Error())
+		}
+	}
+
+	// ----| Close database interface
+	db_close_err := sqlite_utils.Close_database_interface(database_interface)
+	if db_close_err != nil {
+		return nil, db_close_err
+	}
+	return candidate_names, nil
+}
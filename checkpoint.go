@@ -0,0 +1,377 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"vhost-scout/include/random_utils"
+	"vhost-scout/include/request_utils"
+	"vhost-scout/include/sqlite_utils"
+)
+
+// checkpoint_interval is how often the position of a target is saved while its candidates are probed.
+const checkpoint_interval = 5 * time.Second
+
+// with_database opens the database, runs database_operation on it and closes it again. Targets scanned in parallel
+// take turns.
+func with_database(database_operation func(database_interface *sql.DB) error) error {
+
+	database_mutex.Lock()
+	defer database_mutex.Unlock()
+	database_interface, open_db_interface_err := sqlite_utils.Open_database_interface("db.sqlite")
+	if open_db_interface_err != nil {
+		return errors.New("An error occurred while initializing the database interface || Error: " + open_db_interface_err.Error())
+	}
+
+	database_operation_err := database_operation(database_interface)
+	db_close_err := sqlite_utils.Close_database_interface(database_interface)
+	if database_operation_err != nil {
+		return database_operation_err
+	}
+	return db_close_err
+}
+
+// load_resumed_arguments returns the command line arguments scan_id was started with.
+func load_resumed_arguments(scan_id string) ([]string, error) {
+
+	var scan_row sqlite_utils.Scan_row
+	load_scan_err := with_database(func(database_interface *sql.DB) error {
+		var get_scan_err error
+		scan_row, get_scan_err = sqlite_utils.Get_scan_row(database_interface, scan_id)
+		return get_scan_err
+	})
+	if load_scan_err != nil {
+		return nil, load_scan_err
+	}
+
+	var arguments []string
+	if json_unmarshal_err := json.Unmarshal([]byte(scan_row.Arguments), &arguments); json_unmarshal_err != nil {
+		return nil, errors.New("An error occurred while reading the arguments of scan: " + scan_id + " || Error: " + json_unmarshal_err.Error())
+	}
+	return arguments, nil
+}
+
+// start_scan records a new scan, or loads the checkpoints of the scan being resumed, and returns the scan id together
+// with the checkpoints of the targets the scan already started.
+func start_scan(options t_scan_options) (string, map[string]sqlite_utils.Scan_target_row, error) {
+
+	combinations := strings.Join(scan_combinations(options), ",")
+
+	// ----| Record new scan
+	if options.resume_scan_id == "" {
+		arguments_json, json_marshal_err := json.Marshal(options.arguments)
+		if json_marshal_err != nil {
+			return "", nil, errors.New("An error occurred while serializing the scan arguments || Error: " + json_marshal_err.Error())
+		}
+		scan_row := sqlite_utils.Scan_row{
+			Scan_id:       time.Now().Format("20060102-150405") + "-" + random_utils.Gen_random_string(6),
+			Arguments:     string(arguments_json),
+			Vhosts_count:  options.wordlist.count,
+			Started_at:    time.Now().Format(time.RFC3339),
+			Vhosts_digest: options.wordlist.digest,
+			Combinations:  combinations,
+		}
+		add_scan_err := with_database(func(database_interface *sql.DB) error {
+			return sqlite_utils.AddScanRowToTable(database_interface, scan_row)
+		})
+		return scan_row.Scan_id, map[string]sqlite_utils.Scan_target_row{}, add_scan_err
+	}
+
+	// ----| Load checkpoints of the scan being resumed
+	var scan_row sqlite_utils.Scan_row
+	var scan_targets map[string]sqlite_utils.Scan_target_row
+	load_scan_err := with_database(func(database_interface *sql.DB) error {
+		var get_scan_err error
+		scan_row, get_scan_err = sqlite_utils.Get_scan_row(database_interface, options.resume_scan_id)
+		if get_scan_err != nil {
+			return get_scan_err
+		}
+		scan_targets, get_scan_err = sqlite_utils.Get_scan_target_rows(database_interface, options.resume_scan_id)
+		return get_scan_err
+	})
+	if load_scan_err != nil {
+		return "", nil, load_scan_err
+	}
+
+	// ----| Positions are only meaningful for the exact list and combinations the scan was started with
+	if scan_row.Vhosts_count != options.wordlist.count {
+		return "", nil, errors.New("The vhosts list of scan: " + scan_row.Scan_id + " had " + strconv.Itoa(scan_row.Vhosts_count) + " candidates, it now has " + strconv.Itoa(options.wordlist.count) + ", the scan cannot be resumed")
+	}
+	if scan_row.Vhosts_digest != "" && scan_row.Vhosts_digest != options.wordlist.digest {
+		return "", nil, errors.New("The vhosts list of scan: " + scan_row.Scan_id + " has changed since the scan was started, the scan cannot be resumed")
+	}
+	if scan_row.Combinations != "" && scan_row.Combinations != combinations {
+		return "", nil, errors.New("Scan: " + scan_row.Scan_id + " was started with probe mode / injection vector combinations: " + scan_row.Combinations + ", they are now: " + combinations + ", the scan cannot be resumed")
+	}
+	return scan_row.Scan_id, scan_targets, nil
+}
+
+// t_checkpoint is the progress of a target within a scan. It is only used from the goroutine scanning the target.
+type t_checkpoint struct {
+	row        sqlite_utils.Scan_target_row
+	is_resumed bool
+	hits       map[int][]t_vhost   // Checkpointed hits per combination, loaded when the target is resumed
+	probed     map[int]bool        // Probed candidates past row.Position, the position moves once there are no gaps
+	throttled  map[int]t_candidate // Throttled candidates of the combination being enumerated, by index
+	last_saved time.Time
+	console    io.Writer
+}
+
+// t_throttled_candidate is how a throttled candidate is stored in a checkpoint.
+type t_throttled_candidate struct {
+	Index  int
+	Vhost  string
+	Source string
+}
+
+// new_checkpoint returns the checkpoint of target, loading its hits when the scan already started the target.
+func new_checkpoint(scan_id string, target string, scan_target_row sqlite_utils.Scan_target_row, is_resumed bool, console io.Writer) (*t_checkpoint, error) {
+
+	checkpoint := &t_checkpoint{
+		row:        scan_target_row,
+		is_resumed: is_resumed,
+		hits:       map[int][]t_vhost{},
+		probed:     map[int]bool{},
+		throttled:  map[int]t_candidate{},
+		last_saved: time.Now(),
+		console:    console,
+	}
+	if !is_resumed {
+		checkpoint.row = sqlite_utils.Scan_target_row{Scan_id: scan_id, Target: target, Status: sqlite_utils.Scan_target_status_running, Seed: rand.Int63()}
+		return checkpoint, nil
+	}
+
+	// ----| Load candidates that were throttled and hits found before the scan was interrupted
+	if scan_target_row.Throttled != "" {
+		var throttled_candidates []t_throttled_candidate
+		if json_unmarshal_err := json.Unmarshal([]byte(scan_target_row.Throttled), &throttled_candidates); json_unmarshal_err != nil {
+			return nil, errors.New("An error occurred while reading the throttled candidates of target: " + target + " || Error: " + json_unmarshal_err.Error())
+		}
+		for _, throttled_candidate := range throttled_candidates {
+			checkpoint.throttled[throttled_candidate.Index] = t_candidate{index: throttled_candidate.Index, vhost: throttled_candidate.Vhost, source: throttled_candidate.Source}
+		}
+	}
+	var scan_hit_rows []sqlite_utils.Scan_hit_row
+	load_hits_err := with_database(func(database_interface *sql.DB) error {
+		var get_hits_err error
+		scan_hit_rows, get_hits_err = sqlite_utils.Get_scan_hit_rows(database_interface, scan_id, target)
+		return get_hits_err
+	})
+	if load_hits_err != nil {
+		return nil, load_hits_err
+	}
+	for _, scan_hit_row := range scan_hit_rows {
+		var table_row sqlite_utils.Table_row
+		if json_unmarshal_err := json.Unmarshal([]byte(scan_hit_row.Hit), &table_row); json_unmarshal_err != nil {
+			return nil, errors.New("An error occurred while reading a checkpointed hit of target: " + target + " || Error: " + json_unmarshal_err.Error())
+		}
+		vhost_information, convert_err := table_row_to_vhost(table_row)
+		if convert_err != nil {
+			return nil, convert_err
+		}
+		if !checkpoint.has_hit(scan_hit_row.Combination, vhost_information) {
+			checkpoint.hits[scan_hit_row.Combination] = append(checkpoint.hits[scan_hit_row.Combination], vhost_information)
+		}
+	}
+	return checkpoint, nil
+}
+
+// start saves the names harvested from the target's certificate so a resumed scan probes the exact same candidates.
+func (checkpoint *t_checkpoint) start(certificate_vhosts []string) {
+	checkpoint.row.Certificate_vhosts = strings.Join(certificate_vhosts, ",")
+	checkpoint.save()
+}
+
+// certificate_vhosts returns the names harvested from the target's certificate before the scan was interrupted.
+func (checkpoint *t_checkpoint) certificate_vhosts() []string {
+	if checkpoint.row.Certificate_vhosts == "" {
+		return nil
+	}
+	return strings.Split(checkpoint.row.Certificate_vhosts, ",")
+}
+
+// add_hit saves a hit of the combination being enumerated right away, so it survives a crash. Hits saved before the
+// scan was resumed are probed again when they are past the saved position, add_hit reports whether the hit is new.
+func (checkpoint *t_checkpoint) add_hit(vhost_information t_vhost) bool {
+	combination := checkpoint.row.Combination
+	if checkpoint.has_hit(combination, vhost_information) {
+		return false
+	}
+	checkpoint.hits[combination] = append(checkpoint.hits[combination], vhost_information)
+	save_hit_err := checkpoint.save_hits(combination, []t_vhost{vhost_information})
+	if save_hit_err != nil {
+		fmt.Fprintf(checkpoint.console, "  > Could not checkpoint hit: %s || Error: %s\n\n", vhost_information.vhost, save_hit_err.Error())
+	}
+	return true
+}
+
+// has_hit reports whether the hits of combination already hold the finding.
+func (checkpoint *t_checkpoint) has_hit(combination int, vhost_information t_vhost) bool {
+	return slices.ContainsFunc(checkpoint.hits[combination], func(hit t_vhost) bool {
+		return hit.vhost == vhost_information.vhost && hit.finding_type == vhost_information.finding_type
+	})
+}
+
+// combination_hits returns a copy of the hits checkpointed for the combination being enumerated.
+func (checkpoint *t_checkpoint) combination_hits() []t_vhost {
+	return slices.Clone(checkpoint.hits[checkpoint.row.Combination])
+}
+
+// mark_throttled records that the target throttled candidate, it is kept until it is probed again or given up on.
+func (checkpoint *t_checkpoint) mark_throttled(candidate t_candidate) {
+	checkpoint.throttled[candidate.index] = candidate
+	checkpoint.advance(candidate.index)
+}
+
+// mark_probed records that candidate was probed (or given up on) in the combination being enumerated.
+func (checkpoint *t_checkpoint) mark_probed(candidate t_candidate) {
+	delete(checkpoint.throttled, candidate.index)
+	checkpoint.advance(candidate.index)
+}
+
+// throttled_candidates returns the throttled candidates waiting to be probed again, in stream order.
+func (checkpoint *t_checkpoint) throttled_candidates() []t_candidate {
+	throttled_candidates := slices.Collect(maps.Values(checkpoint.throttled))
+	slices.SortFunc(throttled_candidates, func(a t_candidate, b t_candidate) int {
+		return a.index - b.index
+	})
+	return throttled_candidates
+}
+
+// advance moves the position past index once every candidate before it is accounted for.
+func (checkpoint *t_checkpoint) advance(index int) {
+	if index < checkpoint.row.Position {
+		return // A throttled candidate that was probed again
+	}
+	checkpoint.probed[index] = true
+	for checkpoint.probed[checkpoint.row.Position] {
+		delete(checkpoint.probed, checkpoint.row.Position)
+		checkpoint.row.Position++
+	}
+	if time.Since(checkpoint.last_saved) >= checkpoint_interval {
+		checkpoint.save()
+	}
+}
+
+// complete_combination replaces the hits of the combination being enumerated with its confirmed hits and moves on to
+// the next combination.
+func (checkpoint *t_checkpoint) complete_combination(confirmed_vhosts []t_vhost) {
+	combination := checkpoint.row.Combination
+	checkpoint.hits[combination] = confirmed_vhosts
+	checkpoint.row.Combination = combination + 1
+	checkpoint.row.Position = 0
+	checkpoint.probed = map[int]bool{}
+	checkpoint.throttled = map[int]t_candidate{}
+
+	replace_hits_err := with_database(func(database_interface *sql.DB) error {
+		return sqlite_utils.Delete_scan_hit_rows(database_interface, checkpoint.row.Scan_id, checkpoint.row.Target, combination)
+	})
+	if replace_hits_err == nil {
+		replace_hits_err = checkpoint.save_hits(combination, confirmed_vhosts)
+	}
+	if replace_hits_err != nil {
+		fmt.Fprintf(checkpoint.console, "  > Could not checkpoint confirmed hits || Error: %s\n\n", replace_hits_err.Error())
+	}
+	checkpoint.save()
+}
+
+// finish marks the target as done once its results are in the enumerated_vhosts table.
+func (checkpoint *t_checkpoint) finish() {
+	checkpoint.row.Status = sqlite_utils.Scan_target_status_done
+	checkpoint.save()
+	delete_hits_err := with_database(func(database_interface *sql.DB) error {
+		return sqlite_utils.Delete_scan_hit_rows(database_interface, checkpoint.row.Scan_id, checkpoint.row.Target, -1)
+	})
+	if delete_hits_err != nil {
+		fmt.Fprintf(checkpoint.console, "  > Could not delete checkpointed hits || Error: %s\n\n", delete_hits_err.Error())
+	}
+}
+
+// save writes the target's checkpoint to the database, failures are reported but do not stop the scan.
+func (checkpoint *t_checkpoint) save() {
+	checkpoint.last_saved = time.Now()
+
+	// ----| Throttled candidates are behind the position, they are stored so a resumed scan still probes them
+	checkpoint.row.Throttled = ""
+	if len(checkpoint.throttled) != 0 {
+		var throttled_candidates []t_throttled_candidate
+		for _, candidate := range checkpoint.throttled_candidates() {
+			throttled_candidates = append(throttled_candidates, t_throttled_candidate{Index: candidate.index, Vhost: candidate.vhost, Source: candidate.source})
+		}
+		throttled_json, _ := json.Marshal(throttled_candidates)
+		checkpoint.row.Throttled = string(throttled_json)
+	}
+
+	save_err := with_database(func(database_interface *sql.DB) error {
+		return sqlite_utils.Save_scan_target_row(database_interface, checkpoint.row)
+	})
+	if save_err != nil {
+		fmt.Fprintf(checkpoint.console, "  > Could not save checkpoint of target: %s || Error: %s\n\n", checkpoint.row.Target, save_err.Error())
+	}
+}
+
+func (checkpoint *t_checkpoint) save_hits(combination int, hits []t_vhost) error {
+	return with_database(func(database_interface *sql.DB) error {
+		for _, vhost_information := range hits {
+			table_row, convert_err := vhost_to_table_row(vhost_information)
+			if convert_err != nil {
+				return convert_err
+			}
+			hit_json, json_marshal_err := json.Marshal(table_row)
+			if json_marshal_err != nil {
+				return errors.New("An error occurred while serializing hit: " + vhost_information.vhost + " || Error: " + json_marshal_err.Error())
+			}
+			add_hit_err := sqlite_utils.AddScanHitRowToTable(database_interface, sqlite_utils.Scan_hit_row{
+				Scan_id:     checkpoint.row.Scan_id,
+				Target:      checkpoint.row.Target,
+				Combination: combination,
+				Hit:         string(hit_json),
+			})
+			if add_hit_err != nil {
+				return add_hit_err
+			}
+		}
+		return nil
+	})
+}
+
+// table_row_to_vhost rebuilds a hit from its database row.
+func table_row_to_vhost(table_row sqlite_utils.Table_row) (t_vhost, error) {
+
+	var fingerprint request_utils.ResponseFingerprint
+	if table_row.Response_fingerprint != "" {
+		if json_unmarshal_err := json.Unmarshal([]byte(table_row.Response_fingerprint), &fingerprint); json_unmarshal_err != nil {
+			return t_vhost{}, errors.New("An error occurred while reading the response fingerprint of vhost: " + table_row.Vhost + " || Error: " + json_unmarshal_err.Error())
+		}
+	}
+	var differing_fields []string
+	if table_row.Differing_fields != "" {
+		differing_fields = strings.Split(table_row.Differing_fields, ",")
+	}
+
+	return t_vhost{
+		target:                      table_row.Target,
+		vhost:                       table_row.Vhost,
+		baseline_response_body_md5:  table_row.Baseline_response_body_md5,
+		spoofed_response_body_md5:   table_row.Spoofed_response_body_md5,
+		spoofed_request_status_code: table_row.Spoofed_request_status_code,
+		similarity_distance:         table_row.Similarity_distance,
+		fingerprint:                 fingerprint,
+		differing_fields:            differing_fields,
+		confirmations:               table_row.Confirmations,
+		confidence:                  table_row.Confidence,
+		wildcard_suffix:             table_row.Wildcard_suffix,
+		finding_type:                table_row.Finding_type,
+		probe_mode:                  table_row.Probe_mode,
+		source:                      table_row.Source,
+		injection_vector:            table_row.Injection_vector,
+	}, nil
+}
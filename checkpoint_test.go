@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"slices"
+	"testing"
+	"time"
+	"vhost-scout/include/sqlite_utils"
+)
+
+func Test_checkpoint_advance(t *testing.T) {
+	test_cases := []struct {
+		name     string
+		start    int
+		advanced []int
+		position int
+	}{
+		{"in order", 0, []int{0, 1, 2}, 3},
+		{"gap holds the position", 0, []int{0, 2, 3}, 1},
+		{"gap filled", 0, []int{1, 2, 0}, 3},
+		{"out of order", 0, []int{3, 1, 0, 2, 5}, 4},
+		{"resumed position", 10, []int{10, 12, 11}, 13},
+		{"probed again behind the position", 5, []int{2, 5}, 6},
+		{"nothing before the position", 3, []int{4, 5}, 3},
+	}
+
+	for _, test_case := range test_cases {
+		checkpoint := &t_checkpoint{probed: map[int]bool{}, last_saved: time.Now()} // Recently saved, so advance does not save
+		checkpoint.row.Position = test_case.start
+		for _, index := range test_case.advanced {
+			checkpoint.advance(index)
+		}
+		if checkpoint.row.Position != test_case.position {
+			t.Errorf("%s: position = %d, want %d", test_case.name, checkpoint.row.Position, test_case.position)
+		}
+
+		// ----| Only the probed candidates past the position are remembered
+		for index := range checkpoint.probed {
+			if index < checkpoint.row.Position {
+				t.Errorf("%s: candidate %d behind position %d is still remembered", test_case.name, index, checkpoint.row.Position)
+			}
+		}
+	}
+}
+
+func Test_checkpoint_resume_keeps_hits_unique(t *testing.T) {
+	t.Chdir(t.TempDir()) // with_database opens db.sqlite in the working directory
+
+	// ----| Interrupted scan: two hits were saved, the position never moved past them
+	checkpoint, checkpoint_err := new_checkpoint("scan", "http://example.com", sqlite_utils.Scan_target_row{}, false, io.Discard)
+	if checkpoint_err != nil {
+		t.Fatalf("new_checkpoint returned error: %s", checkpoint_err)
+	}
+	checkpoint.start(nil)
+	for _, vhost := range []string{"admin.example.com", "dev.example.com"} {
+		if !checkpoint.add_hit(t_vhost{target: "http://example.com", vhost: vhost, finding_type: finding_type_vhost}) {
+			t.Errorf("add_hit(%q) reported a new hit as already held", vhost)
+		}
+	}
+
+	// ----| Resumed scan: the candidates past the position are probed again and hit again
+	resumed, resume_err := new_checkpoint("scan", "http://example.com", checkpoint.row, true, io.Discard)
+	if resume_err != nil {
+		t.Fatalf("new_checkpoint returned error when resuming: %s", resume_err)
+	}
+	if resumed.row.Position != 0 {
+		t.Fatalf("resumed position = %d, want 0", resumed.row.Position)
+	}
+	for _, vhost := range []string{"dev.example.com", "admin.example.com"} {
+		if resumed.add_hit(t_vhost{target: "http://example.com", vhost: vhost, finding_type: finding_type_vhost}) {
+			t.Errorf("add_hit(%q) reported a hit saved before the resume as new", vhost)
+		}
+	}
+	if !resumed.add_hit(t_vhost{target: "http://example.com", vhost: "staging.example.com", finding_type: finding_type_vhost}) {
+		t.Errorf("add_hit reported a new hit as already held after the resume")
+	}
+
+	var hits []string
+	for _, vhost_information := range resumed.combination_hits() {
+		hits = append(hits, vhost_information.vhost)
+	}
+	if want := []string{"admin.example.com", "dev.example.com", "staging.example.com"}; !slices.Equal(hits, want) {
+		t.Errorf("combination_hits() = %q, want %q", hits, want)
+	}
+
+	// ----| The saved hits stay unique too
+	reloaded, reload_err := new_checkpoint("scan", "http://example.com", checkpoint.row, true, io.Discard)
+	if reload_err != nil {
+		t.Fatalf("new_checkpoint returned error when reloading: %s", reload_err)
+	}
+	if hits_count := len(reloaded.combination_hits()); hits_count != 3 {
+		t.Errorf("reloaded %d hit(s), want 3", hits_count)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"sync"
+)
+
+// stdout_mutex serializes writes to stdout so lines and flushed target blocks never interleave. It also guards
+// live_console and waiting_consoles.
+var stdout_mutex sync.Mutex
+
+// ----| Targets in flight take turns on stdout: one prints live, the others are buffered until it is their turn
+var live_console *t_console
+var waiting_consoles []*t_console
+
+// t_console is where the output of a single target is written. An unshared console writes straight to stdout. Shared
+// consoles take turns so the output of parallel targets never interleaves: the first target in flight prints live and
+// the output of the others is held until it is their turn, they then print what they held as one block and carry on
+// live. A single target is therefore never buffered.
+type t_console struct {
+	shared bool
+	done   bool // Flushed while waiting, the block is printed when its turn comes
+	buffer bytes.Buffer
+}
+
+func new_console(shared bool) *t_console {
+	console := &t_console{shared: shared}
+	if !shared {
+		return console
+	}
+
+	stdout_mutex.Lock()
+	defer stdout_mutex.Unlock()
+	if live_console == nil {
+		live_console = console
+	} else {
+		waiting_consoles = append(waiting_consoles, console)
+	}
+	return console
+}
+
+func (console *t_console) Write(output []byte) (int, error) {
+	stdout_mutex.Lock()
+	defer stdout_mutex.Unlock()
+	if console == nil || !console.shared || console == live_console {
+		return os.Stdout.Write(output)
+	}
+	return console.buffer.Write(output)
+}
+
+// Flush is called once the target is done and hands stdout to the target that has been waiting longest.
+func (console *t_console) Flush() {
+	if console == nil || !console.shared {
+		return
+	}
+
+	stdout_mutex.Lock()
+	defer stdout_mutex.Unlock()
+	console.done = true
+	if console != live_console {
+		return
+	}
+
+	// ----| Print the blocks of targets that finished while waiting, the first one still running goes live
+	live_console = nil
+	for len(waiting_consoles) != 0 && live_console == nil {
+		next_console := waiting_consoles[0]
+		waiting_consoles = waiting_consoles[1:]
+		os.Stdout.Write(next_console.buffer.Bytes())
+		next_console.buffer = bytes.Buffer{}
+		if !next_console.done {
+			live_console = next_console
+		}
+	}
+}
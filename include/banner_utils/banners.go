@@ -135,7 +135,9 @@ const Minion = `
 ⠂⢀⠀⠀⠂⠀⠀⠈⢺⠀⢍⣻⡞⣿⡽⣳⣿⣷⣻⢿⡾⣝⣯⢿⣯⢿⣿⢯⣿⣿⣻⣿⣿⣿⣿⣿⣿⣿⣿⣟⣿⣿⣯⣿⣿⣿⣿⣿⣿⣿⣿⣿⣿⡟⢦⢹⠂⠀⠀⠈⠀⠀⡀⠁⠀⡀⠀⠁⠀
 `
 
-func Print_banner(targets []string, vhosts_list []string) {
+// Print_banner prints a random banner followed by the first targets and vhosts, the lists are streamed so only their
+// first entries and counts are passed in.
+func Print_banner(targets_preview []string, targets_count int, vhosts_preview []string, vhosts_count int) {
 
 	var banner_art []string
 	banner_art = append(banner_art, Guy_pointing)
@@ -146,20 +148,20 @@ func Print_banner(targets []string, vhosts_list []string) {
 
 	fmt.Println(banner_art[rand.Intn(len(banner_art))]) // Print random banner
 
-	fmt.Println("▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁\n\n")
+	fmt.Print("▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁\n\n\n")
 
-	if len(targets) > 10 {
-		fmt.Println("Targets: " + strings.Join(targets[:10], ", ") + ", " + strconv.Itoa(len(targets)-10) + " more targets")
+	if targets_count > len(targets_preview) {
+		fmt.Println("Targets: " + strings.Join(targets_preview, ", ") + ", " + strconv.Itoa(targets_count-len(targets_preview)) + " more targets")
 	} else {
-		fmt.Println("Targets: " + strings.Join(targets, ", "))
+		fmt.Println("Targets: " + strings.Join(targets_preview, ", "))
 	}
 
 	print("\n")
 
-	if len(vhosts_list) > 10 {
-		fmt.Println("VHosts: " + strings.Join(vhosts_list[:10], ", ") + ", " + strconv.Itoa(len(vhosts_list)-10) + " more vhosts")
+	if vhosts_count > len(vhosts_preview) {
+		fmt.Println("VHosts: " + strings.Join(vhosts_preview, ", ") + ", " + strconv.Itoa(vhosts_count-len(vhosts_preview)) + " more vhosts")
 	} else {
-		fmt.Println("VHosts: " + strings.Join(vhosts_list, ", "))
+		fmt.Println("VHosts: " + strings.Join(vhosts_preview, ", "))
 	}
 
 	print("\n")
@@ -0,0 +1,256 @@
+package baseline_utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"vhost-scout/include/compare_utils"
+	"vhost-scout/include/request_utils"
+)
+
+// ----| Fields of a ResponseFingerprint that candidates can be compared on
+const (
+	Compare_field_body         = "body"
+	Compare_field_status       = "status"
+	Compare_field_length       = "length"
+	Compare_field_words        = "words"
+	Compare_field_lines        = "lines"
+	Compare_field_title        = "title"
+	Compare_field_location     = "location"
+	Compare_field_content_type = "content-type"
+	Compare_field_server       = "server"
+	Compare_field_cookies      = "cookies"
+)
+
+var All_compare_fields = []string{
+	Compare_field_body,
+	Compare_field_status,
+	Compare_field_length,
+	Compare_field_words,
+	Compare_field_lines,
+	Compare_field_title,
+	Compare_field_location,
+	Compare_field_content_type,
+	Compare_field_server,
+	Compare_field_cookies,
+}
+
+// Parse_compare_fields parses a comma separated list of compare fields, "all" selects every field.
+func Parse_compare_fields(compare_fields_list string) ([]string, error) {
+
+	if strings.TrimSpace(compare_fields_list) == "all" {
+		return All_compare_fields, nil
+	}
+
+	var compare_fields []string
+	for _, compare_field := range strings.Split(compare_fields_list, ",") {
+		compare_field = strings.ToLower(strings.TrimSpace(compare_field))
+		if compare_field == "" {
+			continue
+		}
+
+		is_known_field := false
+		for _, known_field := range All_compare_fields {
+			if compare_field == known_field {
+				is_known_field = true
+			}
+		}
+		if !is_known_field {
+			return nil, errors.New("Unknown compare field: " + compare_field + " (valid fields: " + strings.Join(All_compare_fields, ", ") + ", all)")
+		}
+		compare_fields = append(compare_fields, compare_field)
+	}
+
+	if len(compare_fields) == 0 {
+		return nil, errors.New("At least one compare field is required")
+	}
+	return compare_fields, nil
+}
+
+// Baseline_sample is the response observed for a single probe with a random, non-existent Host header.
+type Baseline_sample struct {
+	Vhost       string
+	Fingerprint request_utils.ResponseFingerprint
+}
+
+// t_numeric_range is the range of values a numeric fingerprint field took across the baseline samples.
+type t_numeric_range struct {
+	Min int64
+	Max int64
+}
+
+func (numeric_range *t_numeric_range) add(value int64, is_first bool) {
+	if is_first || value < numeric_range.Min {
+		numeric_range.Min = value
+	}
+	if is_first || value > numeric_range.Max {
+		numeric_range.Max = value
+	}
+}
+
+// contains reports whether value is within the range widened by Length_tolerance_ratio.
+func (numeric_range t_numeric_range) contains(value int64) bool {
+	tolerance := int64(float64(numeric_range.Max) * Length_tolerance_ratio)
+	return value >= numeric_range.Min-tolerance && value <= numeric_range.Max+tolerance
+}
+
+// Baseline_model describes how a target answers requests for vhosts it does not know about. It is
+// built from several Baseline_samples so that targets with dynamic content do not turn every
+// candidate into a hit.
+type Baseline_model struct {
+	Samples         []Baseline_sample
+	Body_md5_hashes map[string]int // md5 -> number of samples with that md5
+	Status_codes    map[int]int    // status code -> number of samples with that status code
+	Body_length     t_numeric_range
+	Word_count      t_numeric_range
+	Line_count      t_numeric_range
+	Header_values   map[string]map[string]bool // compare field -> values seen for it (title, location, ...)
+	Stability       float64                    // Fraction of samples that share the most common body md5 (1.0 == fully static)
+	Max_distance    float64                    // Largest simhash distance between two baseline samples
+	Threshold       float64                    // Candidates further than this from every sample are outside the model
+}
+
+// Length_tolerance_ratio widens the observed length, word and line ranges of the baseline so that
+// small fluctuations are still considered part of the baseline.
+const Length_tolerance_ratio = 0.05
+
+// string_field_value returns the value of a non-numeric compare field.
+func string_field_value(fingerprint request_utils.ResponseFingerprint, compare_field string) string {
+	switch compare_field {
+	case Compare_field_title:
+		return fingerprint.Title
+	case Compare_field_location:
+		return fingerprint.Location
+	case Compare_field_content_type:
+		return fingerprint.Content_type
+	case Compare_field_server:
+		return fingerprint.Server
+	case Compare_field_cookies:
+		return fingerprint.Cookie_names_key()
+	}
+	return ""
+}
+
+var string_compare_fields = []string{Compare_field_title, Compare_field_location, Compare_field_content_type, Compare_field_server, Compare_field_cookies}
+
+func Build_baseline_model(samples []Baseline_sample, similarity_threshold float64) (Baseline_model, error) {
+
+	// ----| Ensure there is at least one sample to build the model from
+	if len(samples) == 0 {
+		return Baseline_model{}, errors.New("Cannot build a baseline model without any baseline samples")
+	}
+
+	baseline_model := Baseline_model{
+		Samples:         samples,
+		Body_md5_hashes: map[string]int{},
+		Status_codes:    map[int]int{},
+		Header_values:   map[string]map[string]bool{},
+	}
+	for _, compare_field := range string_compare_fields {
+		baseline_model.Header_values[compare_field] = map[string]bool{}
+	}
+
+	most_common_md5_count := 0
+	for i, sample := range samples {
+		fingerprint := sample.Fingerprint
+		baseline_model.Body_md5_hashes[fingerprint.Body_md5]++
+		baseline_model.Status_codes[fingerprint.Status_code]++
+
+		if baseline_model.Body_md5_hashes[fingerprint.Body_md5] > most_common_md5_count {
+			most_common_md5_count = baseline_model.Body_md5_hashes[fingerprint.Body_md5]
+		}
+		baseline_model.Body_length.add(fingerprint.Content_length, i == 0)
+		baseline_model.Word_count.add(int64(fingerprint.Word_count), i == 0)
+		baseline_model.Line_count.add(int64(fingerprint.Line_count), i == 0)
+		for _, compare_field := range string_compare_fields {
+			baseline_model.Header_values[compare_field][string_field_value(fingerprint, compare_field)] = true
+		}
+
+		// ----| Measure how far apart the baseline samples are from each other
+		for _, other_sample := range samples[i+1:] {
+			distance := compare_utils.Simhash_distance(fingerprint.Body_simhash, other_sample.Fingerprint.Body_simhash)
+			if distance > baseline_model.Max_distance {
+				baseline_model.Max_distance = distance
+			}
+		}
+	}
+	baseline_model.Stability = float64(most_common_md5_count) / float64(len(samples))
+
+	// ----| A baseline that varies more than the configured threshold on its own widens the threshold
+	baseline_model.Threshold = similarity_threshold
+	if baseline_model.Max_distance > baseline_model.Threshold {
+		baseline_model.Threshold = baseline_model.Max_distance
+	}
+
+	return baseline_model, nil
+}
+
+// Representative_md5 returns the most common body md5 observed while calibrating.
+func (baseline_model Baseline_model) Representative_md5() string {
+	representative_md5, representative_md5_count := "", 0
+	for body_md5, count := range baseline_model.Body_md5_hashes {
+		if count > representative_md5_count {
+			representative_md5, representative_md5_count = body_md5, count
+		}
+	}
+	return representative_md5
+}
+
+// Distance returns the simhash distance between a candidate body and the closest baseline sample.
+func (baseline_model Baseline_model) Distance(body_simhash uint64) float64 {
+	closest_distance := 1.0
+	for _, sample := range baseline_model.Samples {
+		distance := compare_utils.Simhash_distance(body_simhash, sample.Fingerprint.Body_simhash)
+		if distance < closest_distance {
+			closest_distance = distance
+		}
+	}
+	return closest_distance
+}
+
+// Is_outside_model compares a candidate fingerprint against the baseline on the given compare fields. It returns
+// the fields on which the candidate falls outside the model (a candidate is a hit when there is at least one) and
+// the simhash distance between the candidate body and the closest baseline sample.
+//
+// The body field is outside the model when the body md5 was not seen during calibration and the body is further
+// than the model's threshold from every baseline sample. Numeric fields are outside the model when they fall outside
+// the (tolerance widened) range seen during calibration, all other fields when their value was never seen.
+func (baseline_model Baseline_model) Is_outside_model(fingerprint request_utils.ResponseFingerprint, compare_fields []string) ([]string, float64) {
+
+	distance := 0.0
+	if baseline_model.Body_md5_hashes[fingerprint.Body_md5] == 0 {
+		distance = baseline_model.Distance(fingerprint.Body_simhash)
+	}
+
+	var differing_fields []string
+	for _, compare_field := range compare_fields {
+		is_outside := false
+		switch compare_field {
+		case Compare_field_body:
+			is_outside = baseline_model.Body_md5_hashes[fingerprint.Body_md5] == 0 && distance > baseline_model.Threshold
+		case Compare_field_status:
+			is_outside = baseline_model.Status_codes[fingerprint.Status_code] == 0
+		case Compare_field_length:
+			is_outside = !baseline_model.Body_length.contains(fingerprint.Content_length)
+		case Compare_field_words:
+			is_outside = !baseline_model.Word_count.contains(int64(fingerprint.Word_count))
+		case Compare_field_lines:
+			is_outside = !baseline_model.Line_count.contains(int64(fingerprint.Line_count))
+		default:
+			is_outside = !baseline_model.Header_values[compare_field][string_field_value(fingerprint, compare_field)]
+		}
+
+		if is_outside {
+			differing_fields = append(differing_fields, compare_field)
+		}
+	}
+	return differing_fields, distance
+}
+
+// Describe returns a one line summary of the model for the console.
+func (baseline_model Baseline_model) Describe() string {
+	return "Stability: " + strconv.Itoa(int(baseline_model.Stability*100)) + "%" +
+		", Distinct bodies: " + strconv.Itoa(len(baseline_model.Body_md5_hashes)) +
+		", Body length: " + strconv.FormatInt(baseline_model.Body_length.Min, 10) + "-" + strconv.FormatInt(baseline_model.Body_length.Max, 10) +
+		", Distance threshold: " + strconv.FormatFloat(baseline_model.Threshold, 'f', 2, 64)
+}
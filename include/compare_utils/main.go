@@ -0,0 +1,58 @@
+package compare_utils
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Simhash_bits is the width of the simhash fingerprint, distances are reported as a fraction of it.
+const Simhash_bits = 64
+
+// tokenize splits a response body into lowercase word tokens. Markup punctuation is treated as a
+// separator so that html tags and attribute names become tokens of their own.
+func tokenize(body []byte) []string {
+	return strings.FieldsFunc(strings.ToLower(string(body)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	})
+}
+
+// Simhash generates a 64 bit simhash of the body's tokens. Bodies with similar token content
+// produce fingerprints with a small hamming distance, unlike md5 where one changed byte changes everything.
+// https://en.wikipedia.org/wiki/SimHash
+func Simhash(body []byte) uint64 {
+
+	var bit_weights [Simhash_bits]int
+	for _, token := range tokenize(body) {
+		token_hash := fnv.New64a()
+		token_hash.Write([]byte(token))
+		token_hash_sum := token_hash.Sum64()
+
+		for bit := range Simhash_bits {
+			if token_hash_sum&(1<<uint(bit)) != 0 {
+				bit_weights[bit]++
+			} else {
+				bit_weights[bit]--
+			}
+		}
+	}
+
+	var simhash uint64
+	for bit, weight := range bit_weights {
+		if weight > 0 {
+			simhash |= 1 << uint(bit)
+		}
+	}
+	return simhash
+}
+
+// Hamming_distance returns the number of bits that differ between two simhashes.
+func Hamming_distance(simhash_a uint64, simhash_b uint64) int {
+	return bits.OnesCount64(simhash_a ^ simhash_b)
+}
+
+// Simhash_distance returns the hamming distance between two simhashes as a fraction between 0.0 (identical) and 1.0.
+func Simhash_distance(simhash_a uint64, simhash_b uint64) float64 {
+	return float64(Hamming_distance(simhash_a, simhash_b)) / Simhash_bits
+}
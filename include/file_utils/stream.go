@@ -0,0 +1,103 @@
+package file_utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+)
+
+// Stdin_path is the path that reads from standard input.
+const Stdin_path = "-"
+
+// Max_line_length is the longest line a Line_stream reads, longer lines fail the stream.
+const Max_line_length = 1 << 20
+
+var gzip_magic = []byte{0x1f, 0x8b}
+
+// Line_stream reads the lines of a plain or gzip compressed file one at a time, so files of any size can be read in
+// constant memory. Compression is detected from the content, not the file extension.
+type Line_stream struct {
+	path    string
+	file    *os.File
+	scanner *bufio.Scanner
+}
+
+// Open_line_stream opens path for reading line by line, Stdin_path reads standard input.
+func Open_line_stream(path string) (*Line_stream, error) {
+
+	file := os.Stdin
+	if path != Stdin_path {
+		opened_file, file_open_err := os.Open(path)
+		if file_open_err != nil {
+			return nil, file_open_err
+		}
+		file = opened_file
+	}
+
+	// ----| Decompress gzip files
+	var reader io.Reader = bufio.NewReader(file)
+	magic, _ := reader.(*bufio.Reader).Peek(len(gzip_magic))
+	if bytes.Equal(magic, gzip_magic) {
+		gzip_reader, gzip_err := gzip.NewReader(reader)
+		if gzip_err != nil {
+			close_file(file)
+			return nil, errors.New("An error occurred while decompressing file: " + path + " || Error: " + gzip_err.Error())
+		}
+		reader = gzip_reader
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64<<10), Max_line_length)
+	return &Line_stream{path: path, file: file, scanner: scanner}, nil
+}
+
+// Next returns the next line, false once the stream is exhausted or failed (see Err).
+func (line_stream *Line_stream) Next() (string, bool) {
+	if !line_stream.scanner.Scan() {
+		return "", false
+	}
+	return line_stream.scanner.Text(), true
+}
+
+// Err returns the error that stopped the stream early, if any.
+func (line_stream *Line_stream) Err() error {
+	if scanner_err := line_stream.scanner.Err(); scanner_err != nil {
+		return errors.New("An error occurred while reading file: " + line_stream.path + " || Error: " + scanner_err.Error())
+	}
+	return nil
+}
+
+func (line_stream *Line_stream) Close() error {
+	return close_file(line_stream.file)
+}
+
+// close_file closes file unless it is standard input.
+func close_file(file *os.File) error {
+	if file == os.Stdin {
+		return nil
+	}
+	return file.Close()
+}
+
+// Spool_stdin copies standard input to a temporary file so it can be read more than once (e.g. once per target)
+// without keeping it in memory. The caller removes the returned file.
+func Spool_stdin() (string, error) {
+
+	spool_file, create_err := os.CreateTemp("", "vhost-scout-stdin-*")
+	if create_err != nil {
+		return "", errors.New("An error occurred while creating a file to spool stdin to || Error: " + create_err.Error())
+	}
+	_, copy_err := io.Copy(spool_file, os.Stdin)
+	close_err := spool_file.Close()
+	if copy_err == nil {
+		copy_err = close_err
+	}
+	if copy_err != nil {
+		os.Remove(spool_file.Name())
+		return "", errors.New("An error occurred while spooling stdin || Error: " + copy_err.Error())
+	}
+	return spool_file.Name(), nil
+}
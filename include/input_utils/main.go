@@ -1,8 +1,6 @@
 package input_utils
 
 import (
-	"net"
-	"net/url"
 	"os"
 )
 
@@ -14,23 +12,3 @@ func IsFile(path string) bool {
 	}
 	return !info.IsDir()
 }
-
-// IsDomainOrURL checks if the input looks like a domain or URL
-func IsDomainOrURL(input string) bool {
-	// Try URL parse
-	parsed_url, url_parsing_err := url.Parse(input)
-	if url_parsing_err == nil {
-		// Case 1: Full URL with scheme (http://example.com)
-		if parsed_url.Scheme != "" && parsed_url.Host != "" {
-			return true
-		}
-	}
-
-	// Case 2: Plain domain name (example.com)
-	_, host_name_lookup_err := net.LookupHost(input)
-	if host_name_lookup_err == nil {
-		return true
-	}
-
-	return false
-}
@@ -0,0 +1,177 @@
+package input_utils
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// ----| Reasons a line of a targets or vhosts list is dropped
+const (
+	Drop_reason_blank     = "blank"
+	Drop_reason_comment   = "comment"
+	Drop_reason_duplicate = "duplicate"
+	Drop_reason_invalid   = "invalid"
+)
+
+var drop_reasons = []string{Drop_reason_blank, Drop_reason_comment, Drop_reason_duplicate, Drop_reason_invalid}
+
+// max_invalid_examples is the number of invalid lines quoted in a Line_filter summary.
+const max_invalid_examples = 5
+
+// idna_profile converts internationalized names to punycode. Labels are validated by Normalize_hostname, which also
+// accepts the underscores some internal vhosts use.
+var idna_profile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false), idna.BidiRule(), idna.Transitional(false))
+
+// Normalize_hostname lowercases a hostname, converts it to punycode and checks that it is a valid hostname (labels of
+// letters, digits, hyphens and underscores, at most 63 characters each and 253 in total) or an IP address.
+func Normalize_hostname(hostname string) (string, error) {
+
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	if net.ParseIP(hostname) != nil {
+		return hostname, nil
+	}
+
+	ascii_hostname, idna_err := idna_profile.ToASCII(hostname)
+	if idna_err != nil {
+		return "", errors.New("Not a valid hostname: " + hostname + " || Error: " + idna_err.Error())
+	}
+	if len(ascii_hostname) == 0 || len(ascii_hostname) > 253 {
+		return "", errors.New("Not a valid hostname: " + hostname + " (length must be 1-253)")
+	}
+	for _, label := range strings.Split(ascii_hostname, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return "", errors.New("Not a valid hostname: " + hostname + " (labels must be 1-63 characters)")
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return "", errors.New("Not a valid hostname: " + hostname + " (labels cannot start or end with a hyphen)")
+		}
+		for _, character := range label {
+			if !(character >= 'a' && character <= 'z' || character >= '0' && character <= '9' || character == '-' || character == '_') {
+				return "", errors.New("Not a valid hostname: " + hostname + " (invalid character " + strconv.QuoteRune(character) + ")")
+			}
+		}
+	}
+	return ascii_hostname, nil
+}
+
+// Dedupe_window is the number of most recently kept lines a Line_filter compares new lines against. It bounds the
+// memory of every filter, duplicates further apart than that are kept (sorted lists have theirs next to each other).
+const Dedupe_window = 100000
+
+// Recent_lines remembers the last lines added to it, at most size of them, so streams of any length can be deduped
+// in bounded memory. Two Recent_lines given the same lines make the same decisions.
+type Recent_lines struct {
+	size         int
+	lines        map[string]struct{}
+	lines_order  []string // Ring of the lines in lines, next_evicted is the oldest
+	next_evicted int
+}
+
+func New_recent_lines(size int) *Recent_lines {
+	return &Recent_lines{size: size, lines: map[string]struct{}{}}
+}
+
+// Add remembers line and reports whether it is new, false when it is one of the last size lines. The oldest line is
+// forgotten once size lines are remembered.
+func (recent_lines *Recent_lines) Add(line string) bool {
+	if _, is_duplicate := recent_lines.lines[line]; is_duplicate {
+		return false
+	}
+	if len(recent_lines.lines_order) < recent_lines.size {
+		recent_lines.lines_order = append(recent_lines.lines_order, line)
+	} else {
+		delete(recent_lines.lines, recent_lines.lines_order[recent_lines.next_evicted])
+		recent_lines.lines_order[recent_lines.next_evicted] = line
+		recent_lines.next_evicted = (recent_lines.next_evicted + 1) % recent_lines.size
+	}
+	recent_lines.lines[line] = struct{}{}
+	return true
+}
+
+// Len returns the number of lines remembered.
+func (recent_lines *Recent_lines) Len() int {
+	return len(recent_lines.lines)
+}
+
+// Line_filter normalizes the lines of a targets or vhosts list and drops blank lines, comments (everything after a #),
+// duplicates of one of the last Dedupe_window kept lines and lines that fail normalization. Two filters given the same
+// lines keep the same ones.
+type Line_filter struct {
+	normalize        func(line string) (string, error)
+	recent           *Recent_lines // Last kept lines
+	Kept             int
+	Dropped          map[string]int // Number of dropped lines per Drop_reason_*
+	Invalid_examples []string
+}
+
+func new_line_filter(normalize func(line string) (string, error)) *Line_filter {
+	return &Line_filter{normalize: normalize, recent: New_recent_lines(Dedupe_window), Dropped: map[string]int{}}
+}
+
+// New_vhost_filter returns a Line_filter for vhosts lists.
+func New_vhost_filter() *Line_filter {
+	return new_line_filter(Normalize_hostname)
+}
+
+// New_target_filter returns a Line_filter for targets lists, whose lines can be ranges (see Normalize_target_expression).
+func New_target_filter() *Line_filter {
+	return new_line_filter(Normalize_target_expression)
+}
+
+// Filter returns the normalized line, false when the line is dropped.
+func (line_filter *Line_filter) Filter(line string) (string, bool) {
+
+	// ----| Strip byte order mark, CRLF endings, whitespace and comments
+	line = strings.TrimPrefix(line, "\ufeff")
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return line_filter.drop(Drop_reason_blank)
+	}
+	if comment_start := strings.IndexByte(line, '#'); comment_start >= 0 {
+		line = strings.TrimSpace(line[:comment_start])
+		if line == "" {
+			return line_filter.drop(Drop_reason_comment)
+		}
+	}
+
+	normalized_line, normalize_err := line_filter.normalize(line)
+	if normalize_err != nil {
+		if len(line_filter.Invalid_examples) < max_invalid_examples {
+			line_filter.Invalid_examples = append(line_filter.Invalid_examples, strconv.Quote(line))
+		}
+		return line_filter.drop(Drop_reason_invalid)
+	}
+
+	if !line_filter.recent.Add(normalized_line) {
+		return line_filter.drop(Drop_reason_duplicate)
+	}
+	line_filter.Kept++
+	return normalized_line, true
+}
+
+func (line_filter *Line_filter) drop(reason string) (string, bool) {
+	line_filter.Dropped[reason]++
+	return "", false
+}
+
+// Summary describes the dropped lines (e.g. "2 blank, 1 duplicate, 1 invalid: \"foo bar\""), empty when none were.
+func (line_filter *Line_filter) Summary() string {
+	var dropped []string
+	for _, reason := range drop_reasons {
+		if line_filter.Dropped[reason] != 0 {
+			dropped = append(dropped, strconv.Itoa(line_filter.Dropped[reason])+" "+reason)
+		}
+	}
+	summary := strings.Join(dropped, ", ")
+	if len(line_filter.Invalid_examples) != 0 {
+		summary += ": " + strings.Join(line_filter.Invalid_examples, ", ")
+		if line_filter.Dropped[Drop_reason_invalid] > len(line_filter.Invalid_examples) {
+			summary += ", ..."
+		}
+	}
+	return summary
+}
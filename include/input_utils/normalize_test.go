@@ -0,0 +1,98 @@
+package input_utils
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func Test_line_filter(t *testing.T) {
+	lines := []string{
+		"\ufeffAdmin.Example.com",
+		"",
+		"   ",
+		"# comment",
+		"www.example.com  # trailing comment",
+		"admin.example.com",
+		"ADMIN.example.com.",
+		"bad host.com",
+		"-bad.example.com",
+		"bücher.example.com",
+		"internal_app.corp.local\r",
+	}
+
+	line_filter := New_vhost_filter()
+	var kept []string
+	for _, line := range lines {
+		if normalized_line, keep := line_filter.Filter(line); keep {
+			kept = append(kept, normalized_line)
+		}
+	}
+
+	want_kept := []string{"admin.example.com", "www.example.com", "xn--bcher-kva.example.com", "internal_app.corp.local"}
+	if !slices.Equal(kept, want_kept) {
+		t.Errorf("kept %q, want %q", kept, want_kept)
+	}
+	if line_filter.Kept != len(want_kept) {
+		t.Errorf("Kept = %d, want %d", line_filter.Kept, len(want_kept))
+	}
+	want_dropped := map[string]int{Drop_reason_blank: 2, Drop_reason_comment: 1, Drop_reason_duplicate: 2, Drop_reason_invalid: 2}
+	for reason, count := range want_dropped {
+		if line_filter.Dropped[reason] != count {
+			t.Errorf("Dropped[%s] = %d, want %d", reason, line_filter.Dropped[reason], count)
+		}
+	}
+	want_summary := `2 blank, 1 comment, 2 duplicate, 2 invalid: "bad host.com", "-bad.example.com"`
+	if summary := line_filter.Summary(); summary != want_summary {
+		t.Errorf("Summary() = %q, want %q", summary, want_summary)
+	}
+}
+
+func Test_line_filter_dedupe_window(t *testing.T) {
+	line_filter := New_vhost_filter()
+	line_filter.Filter("first.example.com")
+
+	// ----| Within the window a repeated line is dropped
+	if _, keep := line_filter.Filter("first.example.com"); keep {
+		t.Fatalf("duplicate within the dedupe window was kept")
+	}
+
+	// ----| Once Dedupe_window other lines were kept the first line is forgotten
+	for i := range Dedupe_window {
+		line_filter.Filter("host-" + strconv.Itoa(i) + ".example.com")
+	}
+	if line_filter.recent.Len() != Dedupe_window {
+		t.Errorf("dedupe window holds %d lines, want %d", line_filter.recent.Len(), Dedupe_window)
+	}
+	if _, keep := line_filter.Filter("first.example.com"); !keep {
+		t.Errorf("line older than the dedupe window was dropped")
+	}
+	if _, keep := line_filter.Filter("host-" + strconv.Itoa(Dedupe_window-1) + ".example.com"); keep {
+		t.Errorf("recent duplicate was kept")
+	}
+}
+
+func Test_recent_lines(t *testing.T) {
+	recent_lines := New_recent_lines(2)
+	test_cases := []struct {
+		line   string
+		is_new bool
+	}{
+		{"a", true},
+		{"b", true},
+		{"a", false},
+		{"c", true}, // Forgets a
+		{"b", false},
+		{"a", true}, // Forgets b
+		{"b", true},
+	}
+
+	for i, test_case := range test_cases {
+		if is_new := recent_lines.Add(test_case.line); is_new != test_case.is_new {
+			t.Errorf("Add(%q) #%d = %t, want %t", test_case.line, i, is_new, test_case.is_new)
+		}
+	}
+	if recent_lines.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", recent_lines.Len())
+	}
+}
@@ -0,0 +1,243 @@
+package input_utils
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Max_target_range_size is the largest number of addresses a CIDR block or IP range of a targets list expands to.
+const Max_target_range_size = 1 << 20
+
+// t_target_expression is a parsed line of a targets list: a URL, or a host, CIDR block or IP range with an optional
+// comma separated list of ports.
+type t_target_expression struct {
+	url   string     // Set for a URL, which is scanned as it is
+	hosts string     // Normalized host, CIDR block or IP range
+	first netip.Addr // First and last address of a CIDR block or IP range, invalid for a single host
+	last  netip.Addr
+	ports []string
+}
+
+// Normalize_target_expression parses a line of a targets list and returns it normalized. A line is a URL
+// (http(s)://host[:port][/path]) or a hostname, IP address, IPv6 literal (::1 or [::1]), CIDR block (10.0.0.0/24) or IP
+// range (10.0.0.5-10.0.0.40), optionally followed by a list of ports (host:8080,8443, [2001:db8::1]:8443,
+// 10.0.0.0/24:80,443). Hosts are normalized and IPv6 literals given with ports are put in brackets.
+func Normalize_target_expression(expression string) (string, error) {
+	target_expression, parse_err := parse_target_expression(expression)
+	if parse_err != nil {
+		return "", parse_err
+	}
+	return target_expression.String(), nil
+}
+
+// Expand_target returns a function handing out the targets a line of a targets list expands to, one per address
+// and port, in the form Target_urls takes. The network and broadcast addresses of IPv4 blocks larger than /31 are
+// skipped.
+func Expand_target(expression string) (func() (string, bool), error) {
+
+	target_expression, parse_err := parse_target_expression(expression)
+	if parse_err != nil {
+		return nil, parse_err
+	}
+	if target_expression.url != "" {
+		url_handed_out := false
+		return func() (string, bool) {
+			if url_handed_out {
+				return "", false
+			}
+			url_handed_out = true
+			return target_expression.url, true
+		}, nil
+	}
+
+	ports := target_expression.ports
+	if len(ports) == 0 {
+		ports = []string{""}
+	}
+	address := target_expression.first
+	next_port := 0
+	expanded := false
+	return func() (string, bool) {
+		if expanded {
+			return "", false
+		}
+		target := target_expression.hosts
+		if address.IsValid() {
+			target = bracket_ipv6(address)
+		}
+		if ports[next_port] != "" {
+			target += ":" + ports[next_port]
+		}
+
+		// ----| Every port of an address is handed out before the next address
+		next_port++
+		if next_port == len(ports) {
+			next_port = 0
+			if !address.IsValid() || address == target_expression.last {
+				expanded = true
+			} else {
+				address = address.Next()
+			}
+		}
+		return target, true
+	}, nil
+}
+
+func parse_target_expression(expression string) (t_target_expression, error) {
+
+	// ----| URL, ports and ranges cannot be given in one
+	if strings.Contains(expression, "://") {
+		parsed_url, url_parsing_err := url.Parse(expression)
+		if url_parsing_err != nil {
+			return t_target_expression{}, errors.New("Not a valid URL: " + expression + " || Error: " + url_parsing_err.Error())
+		}
+		parsed_url.Scheme = strings.ToLower(parsed_url.Scheme)
+		if parsed_url.Scheme != Scheme_http && parsed_url.Scheme != Scheme_https {
+			return t_target_expression{}, errors.New("Unsupported scheme in target: " + expression + " (valid schemes: " + Scheme_http + ", " + Scheme_https + ")")
+		}
+		host, host_err := normalize_host_port(parsed_url.Hostname(), parsed_url.Port())
+		if host_err != nil {
+			return t_target_expression{}, host_err
+		}
+		parsed_url.Host = host
+		return t_target_expression{url: parsed_url.String()}, nil
+	}
+	if strings.ContainsAny(expression, "?#@ ") {
+		return t_target_expression{}, errors.New("Not a valid target: " + expression)
+	}
+
+	// ----| Split off the ports. A bare IPv6 literal or block has too many colons to carry ports, it needs brackets
+	hosts, ports_list, has_ports := expression, "", false
+	if strings.HasPrefix(expression, "[") {
+		closing_bracket := strings.IndexByte(expression, ']')
+		if closing_bracket < 0 {
+			return t_target_expression{}, errors.New("Not a valid target: " + expression + " (missing ])")
+		}
+		hosts = expression[1:closing_bracket]
+		if rest := expression[closing_bracket+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return t_target_expression{}, errors.New("Not a valid target: " + expression)
+			}
+			ports_list, has_ports = rest[1:], true
+		}
+	} else if strings.Count(expression, ":") == 1 {
+		hosts, ports_list, _ = strings.Cut(expression, ":")
+		has_ports = true
+	}
+
+	target_expression := t_target_expression{}
+	if has_ports {
+		ports, ports_err := parse_ports(ports_list)
+		if ports_err != nil {
+			return t_target_expression{}, ports_err
+		}
+		target_expression.ports = ports
+	}
+
+	// ----| CIDR block
+	if strings.Contains(hosts, "/") {
+		prefix, prefix_err := netip.ParsePrefix(hosts)
+		if prefix_err != nil {
+			return t_target_expression{}, errors.New("Not a valid CIDR block: " + hosts + " || Error: " + prefix_err.Error())
+		}
+		prefix = prefix.Masked()
+		target_expression.hosts = prefix.String()
+		target_expression.first, target_expression.last = prefix.Addr(), last_address(prefix)
+		if size_err := check_range_size(hosts, target_expression.first, target_expression.last); size_err != nil {
+			return t_target_expression{}, size_err
+		}
+		if prefix.Addr().Is4() && prefix.Bits() <= 30 {
+			target_expression.first, target_expression.last = target_expression.first.Next(), target_expression.last.Prev()
+		}
+		return target_expression, nil
+	}
+
+	// ----| IP range, hostnames may contain hyphens too so the range starts with an address
+	if range_start, range_end, is_range := strings.Cut(hosts, "-"); is_range {
+		if first, first_err := netip.ParseAddr(range_start); first_err == nil {
+			last, last_err := netip.ParseAddr(range_end)
+			if last_err != nil {
+				return t_target_expression{}, errors.New("Not a valid IP range: " + hosts + " || Error: " + last_err.Error())
+			}
+			if first.Zone() != "" || last.Zone() != "" || first.Is4() != last.Is4() || first.Compare(last) > 0 {
+				return t_target_expression{}, errors.New("Not a valid IP range: " + hosts + " (addresses must be of the same family, without zone and in ascending order)")
+			}
+			if size_err := check_range_size(hosts, first, last); size_err != nil {
+				return t_target_expression{}, size_err
+			}
+			target_expression.hosts = first.String() + "-" + last.String()
+			target_expression.first, target_expression.last = first, last
+			return target_expression, nil
+		}
+	}
+
+	// ----| Single host
+	host, host_err := normalize_host_port(hosts, "")
+	if host_err != nil {
+		return t_target_expression{}, host_err
+	}
+	target_expression.hosts = host
+	return target_expression, nil
+}
+
+// String returns the normalized form of the expression.
+func (target_expression t_target_expression) String() string {
+	if target_expression.url != "" {
+		return target_expression.url
+	}
+	if len(target_expression.ports) == 0 {
+		return target_expression.hosts
+	}
+	hosts := target_expression.hosts
+	if target_expression.first.Is6() {
+		hosts = "[" + hosts + "]"
+	}
+	return hosts + ":" + strings.Join(target_expression.ports, ",")
+}
+
+// parse_ports parses a comma separated list of ports, dropping duplicates.
+func parse_ports(ports_list string) ([]string, error) {
+	var ports []string
+	for _, port := range strings.Split(ports_list, ",") {
+		port_number, port_err := strconv.Atoi(strings.TrimSpace(port))
+		if port_err != nil || port_number < 1 || port_number > 65535 {
+			return nil, errors.New("Not a valid port: " + port + " (must be 1-65535)")
+		}
+		if !slices.Contains(ports, strconv.Itoa(port_number)) {
+			ports = append(ports, strconv.Itoa(port_number))
+		}
+	}
+	return ports, nil
+}
+
+// last_address returns the last address of a masked prefix.
+func last_address(prefix netip.Prefix) netip.Addr {
+	address_bytes := prefix.Addr().AsSlice()
+	for bit := range prefix.Addr().BitLen() - prefix.Bits() {
+		address_bytes[len(address_bytes)-1-bit/8] |= 1 << (bit % 8)
+	}
+	last, _ := netip.AddrFromSlice(address_bytes)
+	return last
+}
+
+// check_range_size checks that the range from first to last holds at most Max_target_range_size addresses.
+func check_range_size(hosts string, first netip.Addr, last netip.Addr) error {
+	range_size := new(big.Int).Sub(new(big.Int).SetBytes(last.AsSlice()), new(big.Int).SetBytes(first.AsSlice()))
+	if range_size.Cmp(big.NewInt(Max_target_range_size-1)) > 0 {
+		return errors.New("Range too large: " + hosts + " (at most " + strconv.Itoa(Max_target_range_size) + " addresses, split it up)")
+	}
+	return nil
+}
+
+// bracket_ipv6 returns address as it appears in a host:port, IPv6 addresses in brackets.
+func bracket_ipv6(address netip.Addr) string {
+	if address.Is6() {
+		return "[" + address.String() + "]"
+	}
+	return address.String()
+}
@@ -0,0 +1,161 @@
+package input_utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// expand_all collects every target an expression expands to.
+func expand_all(t *testing.T, expression string) []string {
+	t.Helper()
+	next_target, expand_err := Expand_target(expression)
+	if expand_err != nil {
+		t.Fatalf("Expand_target(%q) returned error: %s", expression, expand_err)
+	}
+	var targets []string
+	for target, has_target := next_target(); has_target; target, has_target = next_target() {
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+func Test_expand_target(t *testing.T) {
+	test_cases := []struct {
+		expression string
+		targets    []string
+	}{
+		{"example.com", []string{"example.com"}},
+		{"Example.COM:8080,8443", []string{"example.com:8080", "example.com:8443"}},
+		{"example.com:8080,8080,08443", []string{"example.com:8080", "example.com:8443"}},
+		{"http://Example.com:8080/path", []string{"http://example.com:8080/path"}},
+		{"10.0.0.5", []string{"10.0.0.5"}},
+		{"::1", []string{"[::1]"}},
+		{"[::1]", []string{"[::1]"}},
+		{"[2001:db8::1]:8443", []string{"[2001:db8::1]:8443"}},
+		{"[2001:db8::1]:80,443", []string{"[2001:db8::1]:80", "[2001:db8::1]:443"}},
+
+		// ----| CIDR blocks, network and broadcast addresses are skipped for IPv4 blocks larger than /31
+		{"10.0.0.0/30", []string{"10.0.0.1", "10.0.0.2"}},
+		{"10.0.0.0/31", []string{"10.0.0.0", "10.0.0.1"}},
+		{"10.0.0.7/32", []string{"10.0.0.7"}},
+		{"10.0.0.5/30", []string{"10.0.0.5", "10.0.0.6"}},
+		{"10.0.0.0/30:80,443", []string{"10.0.0.1:80", "10.0.0.1:443", "10.0.0.2:80", "10.0.0.2:443"}},
+		{"2001:db8::/126", []string{"[2001:db8::]", "[2001:db8::1]", "[2001:db8::2]", "[2001:db8::3]"}},
+		{"[2001:db8::/127]:8443", []string{"[2001:db8::]:8443", "[2001:db8::1]:8443"}},
+
+		// ----| IP ranges
+		{"10.0.0.254-10.0.1.1", []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{"10.0.0.5-10.0.0.5:8080", []string{"10.0.0.5:8080"}},
+		{"[2001:db8::ffff-2001:db8::1:0]:80", []string{"[2001:db8::ffff]:80", "[2001:db8::1:0]:80"}},
+	}
+
+	for _, test_case := range test_cases {
+		if targets := expand_all(t, test_case.expression); !slices.Equal(targets, test_case.targets) {
+			t.Errorf("Expand_target(%q) = %q, want %q", test_case.expression, targets, test_case.targets)
+		}
+	}
+}
+
+func Test_normalize_target_expression(t *testing.T) {
+	test_cases := []struct {
+		expression string
+		normalized string
+	}{
+		{"Example.com", "example.com"},
+		{"example.com:8443,8080", "example.com:8443,8080"},
+		{"10.0.0.5/24", "10.0.0.0/24"},
+		{"10.0.0.0/24:80,443", "10.0.0.0/24:80,443"},
+		{"2001:DB8::/120", "2001:db8::/120"},
+		{"[2001:db8::/120]:443", "[2001:db8::/120]:443"},
+		{"[2001:db8::1]:8443", "[2001:db8::1]:8443"},
+		{"10.0.0.5-10.0.0.40", "10.0.0.5-10.0.0.40"},
+		{"HTTPS://Example.com", "https://example.com"},
+		{"web-01.example.com", "web-01.example.com"},
+	}
+
+	for _, test_case := range test_cases {
+		normalized, normalize_err := Normalize_target_expression(test_case.expression)
+		if normalize_err != nil {
+			t.Errorf("Normalize_target_expression(%q) returned error: %s", test_case.expression, normalize_err)
+			continue
+		}
+		if normalized != test_case.normalized {
+			t.Errorf("Normalize_target_expression(%q) = %q, want %q", test_case.expression, normalized, test_case.normalized)
+		}
+	}
+}
+
+func Test_normalize_target_expression_rejects_invalid(t *testing.T) {
+	invalid_expressions := []string{
+		"ftp://example.com",
+		"example.com/path",
+		"bad host.com",
+		"example.com:0",
+		"example.com:65536",
+		"example.com:",
+		"example.com:80,",
+		"[2001:db8::1",
+		"[2001:db8::1]8443",
+		"10.0.0.0/33",
+		"10.0.0.9-10.0.0.3",
+		"10.0.0.1-2001:db8::1",
+		"10.0.0.1-10.0.0.x",
+		"10.0.0.0/8",
+		"10.0.0.0-10.16.0.0",
+		"2001:db8::/64",
+	}
+
+	for _, expression := range invalid_expressions {
+		if normalized, normalize_err := Normalize_target_expression(expression); normalize_err == nil {
+			t.Errorf("Normalize_target_expression(%q) = %q, want an error", expression, normalized)
+		}
+	}
+}
+
+func Test_range_size_cap(t *testing.T) {
+
+	// ----| 10.0.0.0/12 holds exactly Max_target_range_size addresses
+	if _, normalize_err := Normalize_target_expression("10.0.0.0/12"); normalize_err != nil {
+		t.Errorf("a block of Max_target_range_size addresses was rejected: %s", normalize_err)
+	}
+	if _, normalize_err := Normalize_target_expression("10.0.0.0/11"); normalize_err == nil || !strings.Contains(normalize_err.Error(), "too large") {
+		t.Errorf("a block larger than Max_target_range_size was not rejected as too large: %v", normalize_err)
+	}
+	if _, normalize_err := Normalize_target_expression("10.0.0.0-10.15.255.255"); normalize_err != nil {
+		t.Errorf("a range of Max_target_range_size addresses was rejected: %s", normalize_err)
+	}
+	if _, normalize_err := Normalize_target_expression("10.0.0.0-10.16.0.0"); normalize_err == nil {
+		t.Errorf("a range of Max_target_range_size+1 addresses was accepted")
+	}
+}
+
+func Test_target_urls(t *testing.T) {
+	test_cases := []struct {
+		target  string
+		schemes []string
+		urls    []string
+	}{
+		{"example.com", []string{Scheme_http, Scheme_https}, []string{"http://example.com", "https://example.com"}},
+		{"example.com:8080", []string{Scheme_https}, []string{"https://example.com:8080"}},
+		{"example.com:80", []string{Scheme_http, Scheme_https}, []string{"http://example.com:80"}},
+		{"[::1]:443", []string{Scheme_http, Scheme_https}, []string{"https://[::1]:443"}},
+		{"http://example.com:8443", []string{Scheme_https}, []string{"http://example.com:8443"}},
+	}
+
+	for _, test_case := range test_cases {
+		if urls := Target_urls(test_case.target, test_case.schemes); !slices.Equal(urls, test_case.urls) {
+			t.Errorf("Target_urls(%q, %q) = %q, want %q", test_case.target, test_case.schemes, urls, test_case.urls)
+		}
+	}
+}
+
+func Test_target_filter(t *testing.T) {
+	line_filter := New_target_filter()
+	for _, line := range []string{"10.0.0.5/24", "10.0.0.0/24", "Example.com:8080,8443", "example.com:8080,8443", "example.com/path"} {
+		line_filter.Filter(line)
+	}
+	if line_filter.Kept != 2 || line_filter.Dropped[Drop_reason_duplicate] != 2 || line_filter.Dropped[Drop_reason_invalid] != 1 {
+		t.Errorf("Kept = %d, Dropped = %v, want 2 kept, 2 duplicate and 1 invalid", line_filter.Kept, line_filter.Dropped)
+	}
+}
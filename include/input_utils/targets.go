@@ -0,0 +1,81 @@
+package input_utils
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ----| Schemes a target can be scanned over
+const (
+	Scheme_http  = "http"
+	Scheme_https = "https"
+)
+
+// Is_targets_file decides whether the --targets argument names a targets file or is a single target, without any
+// network I/O: "-" (stdin) and existing files are files, anything else has to parse as a target.
+func Is_targets_file(targets_argument string) (bool, error) {
+	if targets_argument == "-" || IsFile(targets_argument) {
+		return true, nil
+	}
+	if _, normalize_err := Normalize_target_expression(targets_argument); normalize_err != nil {
+		return false, errors.New("--targets is neither an existing file nor a valid target: " + targets_argument + " || Error: " + normalize_err.Error())
+	}
+	return false, nil
+}
+
+// Parse_schemes parses the comma separated schemes targets without a scheme are scanned over.
+func Parse_schemes(schemes_list string) ([]string, error) {
+	var schemes []string
+	for _, scheme := range strings.Split(schemes_list, ",") {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme != Scheme_http && scheme != Scheme_https {
+			return nil, errors.New("Unknown scheme: " + scheme + " (valid schemes: " + Scheme_http + ", " + Scheme_https + ")")
+		}
+		if !slices.Contains(schemes, scheme) {
+			schemes = append(schemes, scheme)
+		}
+	}
+	return schemes, nil
+}
+
+// normalize_host_port normalizes hostname and joins it with port, which is checked when it is not empty.
+func normalize_host_port(hostname string, port string) (string, error) {
+
+	normalized_hostname, hostname_err := Normalize_hostname(hostname)
+	if hostname_err != nil {
+		return "", hostname_err
+	}
+	if strings.Contains(normalized_hostname, ":") {
+		normalized_hostname = "[" + normalized_hostname + "]"
+	}
+	if port == "" {
+		return normalized_hostname, nil
+	}
+	if port_number, port_err := strconv.Atoi(port); port_err != nil || port_number < 1 || port_number > 65535 {
+		return "", errors.New("Not a valid port: " + port + " (must be 1-65535)")
+	}
+	return normalized_hostname + ":" + port, nil
+}
+
+// Target_urls returns the URLs to scan for a normalized target. A target with a scheme is scanned as it is, one
+// without is scanned over every scheme in schemes, unless its port is 80 (http) or 443 (https).
+func Target_urls(target string, schemes []string) []string {
+
+	if strings.Contains(target, "://") {
+		return []string{target}
+	}
+	switch {
+	case strings.HasSuffix(target, ":80"):
+		return []string{Scheme_http + "://" + target}
+	case strings.HasSuffix(target, ":443"):
+		return []string{Scheme_https + "://" + target}
+	}
+
+	var target_urls []string
+	for _, scheme := range schemes {
+		target_urls = append(target_urls, scheme+"://"+target)
+	}
+	return target_urls
+}
@@ -0,0 +1,181 @@
+package match_utils
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"vhost-scout/include/request_utils"
+)
+
+// Number_range is an inclusive range of integers, a single value is a range with Min == Max.
+type Number_range struct {
+	Min int64
+	Max int64
+}
+
+// Number_ranges is a list of ranges parsed from input like "200,301-399". A nil list matches nothing.
+type Number_ranges []Number_range
+
+// Parse_number_ranges parses a comma separated list of numbers and number ranges, "all" matches any number.
+func Parse_number_ranges(number_ranges_list string) (Number_ranges, error) {
+
+	var number_ranges Number_ranges
+	for _, entry := range strings.Split(number_ranges_list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "all" {
+			number_ranges = append(number_ranges, Number_range{Min: 0, Max: 1<<63 - 1})
+			continue
+		}
+
+		range_start, range_end, is_range := strings.Cut(entry, "-")
+		if !is_range {
+			range_end = range_start
+		}
+		min, min_parse_err := strconv.ParseInt(strings.TrimSpace(range_start), 10, 64)
+		max, max_parse_err := strconv.ParseInt(strings.TrimSpace(range_end), 10, 64)
+		if min_parse_err != nil || max_parse_err != nil || min > max {
+			return nil, errors.New("Invalid number or range: " + entry)
+		}
+		number_ranges = append(number_ranges, Number_range{Min: min, Max: max})
+	}
+	return number_ranges, nil
+}
+
+func (number_ranges Number_ranges) Contains(number int64) bool {
+	for _, number_range := range number_ranges {
+		if number >= number_range.Min && number <= number_range.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule_set holds one group of rules (either the match or the filter group) for each response property.
+type Rule_set struct {
+	Status       Number_ranges
+	Size         Number_ranges
+	Words        Number_ranges
+	Lines        Number_ranges
+	Body_regex   *regexp.Regexp
+	Header_regex *regexp.Regexp
+}
+
+// Is_empty reports whether no rule of the set is configured.
+func (rule_set Rule_set) Is_empty() bool {
+	return rule_set.Status == nil && rule_set.Size == nil && rule_set.Words == nil && rule_set.Lines == nil &&
+		rule_set.Body_regex == nil && rule_set.Header_regex == nil
+}
+
+// Matches reports whether any configured rule of the set matches the response.
+func (rule_set Rule_set) Matches(fingerprint request_utils.ResponseFingerprint) bool {
+	switch {
+	case rule_set.Status.Contains(int64(fingerprint.Status_code)):
+		return true
+	case rule_set.Size.Contains(fingerprint.Content_length):
+		return true
+	case rule_set.Words.Contains(int64(fingerprint.Word_count)):
+		return true
+	case rule_set.Lines.Contains(int64(fingerprint.Line_count)):
+		return true
+	case rule_set.Body_regex != nil && rule_set.Body_regex.Match(fingerprint.Body):
+		return true
+	case rule_set.Header_regex != nil && rule_set.Header_regex.MatchString(serialize_headers(fingerprint.Headers)):
+		return true
+	}
+	return false
+}
+
+// serialize_headers renders headers as "Name: value" lines (sorted by name) so header regexes can match on both.
+func serialize_headers(headers http.Header) string {
+	header_names := make([]string, 0, len(headers))
+	for header_name := range headers {
+		header_names = append(header_names, header_name)
+	}
+	sort.Strings(header_names)
+
+	var serialized_headers strings.Builder
+	for _, header_name := range header_names {
+		for _, header_value := range headers[header_name] {
+			serialized_headers.WriteString(header_name + ": " + header_value + "\n")
+		}
+	}
+	return serialized_headers.String()
+}
+
+// Rules decides which responses that differ from the baseline are reported, in the style of ffuf's
+// matchers and filters. When match rules are configured a response must match at least one of them,
+// a response that matches any filter rule is always dropped.
+type Rules struct {
+	Match  Rule_set
+	Filter Rule_set
+}
+
+// Keep reports whether a response that differs from the baseline should be reported as a hit.
+func (rules Rules) Keep(fingerprint request_utils.ResponseFingerprint) bool {
+	if !rules.Match.Is_empty() && !rules.Match.Matches(fingerprint) {
+		return false
+	}
+	return !rules.Filter.Matches(fingerprint)
+}
+
+// Rule_set_input holds the raw command line values of a Rule_set.
+type Rule_set_input struct {
+	Status       string
+	Size         string
+	Words        string
+	Lines        string
+	Body_regex   string
+	Header_regex string
+}
+
+// Parse_rule_set compiles the raw command line values of one rule group. Empty values leave the rule unset.
+func Parse_rule_set(rule_set_input Rule_set_input) (Rule_set, error) {
+
+	var rule_set Rule_set
+	number_rules := []struct {
+		name   string
+		input  string
+		output *Number_ranges
+	}{
+		{"status", rule_set_input.Status, &rule_set.Status},
+		{"size", rule_set_input.Size, &rule_set.Size},
+		{"words", rule_set_input.Words, &rule_set.Words},
+		{"lines", rule_set_input.Lines, &rule_set.Lines},
+	}
+	for _, number_rule := range number_rules {
+		if strings.TrimSpace(number_rule.input) == "" {
+			continue
+		}
+		number_ranges, parse_err := Parse_number_ranges(number_rule.input)
+		if parse_err != nil {
+			return Rule_set{}, errors.New("Invalid " + number_rule.name + " rule: " + number_rule.input + " || Error: " + parse_err.Error())
+		}
+		*number_rule.output = number_ranges
+	}
+
+	regex_rules := []struct {
+		name   string
+		input  string
+		output **regexp.Regexp
+	}{
+		{"regex", rule_set_input.Body_regex, &rule_set.Body_regex},
+		{"header regex", rule_set_input.Header_regex, &rule_set.Header_regex},
+	}
+	for _, regex_rule := range regex_rules {
+		if regex_rule.input == "" {
+			continue
+		}
+		compiled_regex, compile_err := regexp.Compile(regex_rule.input)
+		if compile_err != nil {
+			return Rule_set{}, errors.New("Invalid " + regex_rule.name + " rule: " + regex_rule.input + " || Error: " + compile_err.Error())
+		}
+		*regex_rule.output = compiled_regex
+	}
+	return rule_set, nil
+}
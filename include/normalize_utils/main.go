@@ -0,0 +1,155 @@
+package normalize_utils
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"vhost-scout/include/file_utils"
+)
+
+// Host_placeholder replaces every occurrence of the probed host in a response body.
+const Host_placeholder = "{{VHOST}}"
+
+// Volatile_placeholder is the default replacement for values matched by a normalization rule.
+const Volatile_placeholder = "{{VOLATILE}}"
+
+// Normalization_rule rewrites every match of Pattern in a response body with Replacement.
+type Normalization_rule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Normalizer removes reflected Host values and volatile tokens from response bodies before they are fingerprinted,
+// so that two responses from the same vhost hash the same even when the server echoes per-request values.
+type Normalizer struct {
+	Rules []Normalization_rule
+}
+
+// Default_rules strip values that commonly change between requests to the same page. They are applied in order,
+// so the more specific patterns (uuids, dates) come before the generic token patterns.
+var Default_rules = []Normalization_rule{
+	{"uuid", regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), Volatile_placeholder},
+	{"iso8601 date", regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}(?:[T ]\d{2}:\d{2}(?::\d{2}(?:[.,]\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?)?`), Volatile_placeholder},
+	{"http date", regexp.MustCompile(`\b(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun), \d{1,2} (?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) \d{4} \d{2}:\d{2}:\d{2}(?: [A-Z]{2,5}| [+-]\d{4})?`), Volatile_placeholder},
+	{"time of day", regexp.MustCompile(`\b\d{1,2}:\d{2}:\d{2}(?:[.,]\d+)?\b`), Volatile_placeholder},
+	{"unix timestamp", regexp.MustCompile(`\b1\d{9}(?:\d{3})?\b`), Volatile_placeholder},
+	{"nonce attribute", regexp.MustCompile(`(?i)(nonce|integrity)=(["'])[^"']*(["'])`), `$1=$2` + Volatile_placeholder + `$3`},
+	{"csrf token", regexp.MustCompile(`(?i)((?:csrf|xsrf|authenticity|request[_-]?id|token)[\w-]*["']?\s*(?:[:=]|\s+value=|"\s+content=)\s*["']?)[\w+/=.-]{8,}`), `${1}` + Volatile_placeholder},
+	{"hex token", regexp.MustCompile(`(?i)\b[0-9a-f]{16,}\b`), Volatile_placeholder},
+	{"base64 token", regexp.MustCompile(`[A-Za-z0-9+/_-]{32,}={0,2}`), Volatile_placeholder},
+}
+
+// New_normalizer returns a Normalizer using the Default_rules followed by the rules in custom_rules_path (if not empty).
+func New_normalizer(custom_rules_path string) (*Normalizer, error) {
+
+	normalizer := &Normalizer{}
+	normalizer.Rules = append(normalizer.Rules, Default_rules...)
+
+	if custom_rules_path == "" {
+		return normalizer, nil
+	}
+
+	// ----| Load custom rules from file
+	custom_rules, load_rules_err := Load_rules(custom_rules_path)
+	if load_rules_err != nil {
+		return nil, load_rules_err
+	}
+	normalizer.Rules = append(normalizer.Rules, custom_rules...)
+	return normalizer, nil
+}
+
+// Load_rules reads normalization rules from a file. Each line holds a regular expression, optionally followed by
+// " => " and the replacement to use (defaults to Volatile_placeholder). Blank lines and lines starting with # are ignored.
+func Load_rules(rules_path string) ([]Normalization_rule, error) {
+
+	lines, file_read_err := file_utils.Read_lines(rules_path)
+	if file_read_err != nil {
+		return nil, errors.New("An error occurred while reading normalization rules from file: " + rules_path + " || Error: " + file_read_err.Error())
+	}
+
+	var rules []Normalization_rule
+	for line_number, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern, replacement, has_replacement := strings.Cut(line, " => ")
+		if !has_replacement {
+			replacement = Volatile_placeholder
+		}
+
+		compiled_pattern, compile_err := regexp.Compile(pattern)
+		if compile_err != nil {
+			return nil, errors.New("Invalid normalization rule on line " + strconv.Itoa(line_number+1) + " of file: " + rules_path + " || Error: " + compile_err.Error())
+		}
+		rules = append(rules, Normalization_rule{Name: rules_path + ":" + strconv.Itoa(line_number+1), Pattern: compiled_pattern, Replacement: replacement})
+	}
+	return rules, nil
+}
+
+// Host_pattern returns the pattern Normalize replaces the probed host (with and without port) with, nil when there is
+// no probed host. It is compiled once per probe and shared by everything normalized for that probe.
+func Host_pattern(probed_host string) *regexp.Regexp {
+
+	if probed_host == "" {
+		return nil
+	}
+	host_without_port := probed_host
+	if colon_index := strings.LastIndex(probed_host, ":"); colon_index != -1 && !strings.HasSuffix(probed_host, "]") {
+		host_without_port = probed_host[:colon_index]
+	}
+
+	// ----| The longer alternative comes first so host:port is replaced as a whole
+	pattern := regexp.QuoteMeta(probed_host)
+	if host_without_port != probed_host {
+		pattern += "|" + regexp.QuoteMeta(host_without_port)
+	}
+
+	// ----| A bare label is reflected the way it was sent, matching it in any case would also hit words ("Welcome")
+	if strings.Contains(host_without_port, ".") {
+		pattern = `(?i)` + pattern
+	}
+	return regexp.MustCompile(pattern)
+}
+
+// Normalize replaces the probed host (see Host_pattern) with Host_placeholder and then applies every rule to the body.
+func (normalizer *Normalizer) Normalize(body []byte, host_pattern *regexp.Regexp) []byte {
+
+	// ----| Replace reflected host before the generic rules can mangle it
+	if host_pattern != nil {
+		body = replace_host(body, host_pattern)
+	}
+
+	for _, rule := range normalizer.Rules {
+		body = rule.Pattern.ReplaceAll(body, []byte(rule.Replacement))
+	}
+	return body
+}
+
+// replace_host replaces the matches of host_pattern that are not part of a longer label, so a probed "dev" leaves
+// "developer" alone.
+func replace_host(body []byte, host_pattern *regexp.Regexp) []byte {
+
+	var replaced_body []byte
+	last_end := 0
+	for _, match := range host_pattern.FindAllIndex(body, -1) {
+		if match[0] > 0 && is_label_byte(body[match[0]-1]) || match[1] < len(body) && is_label_byte(body[match[1]]) {
+			continue
+		}
+		replaced_body = append(replaced_body, body[last_end:match[0]]...)
+		replaced_body = append(replaced_body, Host_placeholder...)
+		last_end = match[1]
+	}
+	if replaced_body == nil {
+		return body
+	}
+	return append(replaced_body, body[last_end:]...)
+}
+
+// is_label_byte reports whether character can be part of a hostname label.
+func is_label_byte(character byte) bool {
+	return character >= 'a' && character <= 'z' || character >= 'A' && character <= 'Z' || character >= '0' && character <= '9' || character == '-' || character == '_'
+}
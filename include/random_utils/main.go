@@ -1,6 +1,9 @@
 package random_utils
 
-import "math/rand"
+import (
+	"math/rand"
+	"strconv"
+)
 
 func Gen_random_string(string_length int) string {
 
@@ -17,3 +20,25 @@ func Gen_random_string(string_length int) string {
 	}
 	return random_string
 }
+
+// Gen_random_host returns a random, almost certainly non-existent hostname. The shape argument selects
+// the structure of the name so baseline probes do not all look alike (shape wraps around).
+func Gen_random_host(shape int) string {
+	switch shape % 5 {
+	case 0: // abcdef.com
+		return Gen_random_string(rand.Intn(10)) + ".com"
+	case 1: // abcdef.ghijk.net
+		return Gen_random_string(rand.Intn(10)) + "." + Gen_random_string(rand.Intn(10)) + ".net"
+	case 2: // abcdef (single label)
+		return Gen_random_string(4 + rand.Intn(12))
+	case 3: // abc-1234.org
+		return Gen_random_string(rand.Intn(6)) + "-" + strconv.Itoa(rand.Intn(10000)) + ".org"
+	default: // abcdefghijklmnopqrstu.internal
+		return Gen_random_string(16+rand.Intn(16)) + ".internal"
+	}
+}
+
+// Gen_random_subdomain returns a random label under suffix (e.g. abcdefgh.corp.example.com).
+func Gen_random_subdomain(suffix string) string {
+	return Gen_random_string(8+rand.Intn(8)) + "." + suffix
+}
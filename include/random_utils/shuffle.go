@@ -0,0 +1,51 @@
+package random_utils
+
+import "math/rand"
+
+// Window_shuffle shuffles a stream of unknown length in bounded memory. It keeps a window of items and hands out a
+// random one of them every time the next item of the source comes in, so an item moves at most a window's worth of
+// positions towards the front but can end up anywhere after its position. The order only depends on the items of the
+// source and the seed.
+type Window_shuffle[T any] struct {
+	next        func() (T, bool)
+	window      []T
+	window_size int
+	rng         *rand.Rand
+}
+
+// New_window_shuffle shuffles the items returned by next (false once exhausted) in a window of window_size items.
+func New_window_shuffle[T any](next func() (T, bool), window_size int, seed int64) *Window_shuffle[T] {
+	window_size = max(window_size, 1)
+	return &Window_shuffle[T]{
+		next:        next,
+		window:      make([]T, 0, min(window_size, 4096)),
+		window_size: window_size,
+		rng:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Next returns the next item of the shuffled stream, false once the source and the window are exhausted.
+func (window_shuffle *Window_shuffle[T]) Next() (T, bool) {
+
+	// ----| Fill the window
+	for len(window_shuffle.window) < window_shuffle.window_size {
+		item, has_item := window_shuffle.next()
+		if !has_item {
+			break
+		}
+		window_shuffle.window = append(window_shuffle.window, item)
+	}
+
+	var item T
+	if len(window_shuffle.window) == 0 {
+		return item, false
+	}
+
+	// ----| Hand out a random item of the window, the last item takes its place
+	last := len(window_shuffle.window) - 1
+	picked := window_shuffle.rng.Intn(len(window_shuffle.window))
+	item = window_shuffle.window[picked]
+	window_shuffle.window[picked] = window_shuffle.window[last]
+	window_shuffle.window = window_shuffle.window[:last]
+	return item, true
+}
@@ -0,0 +1,77 @@
+package random_utils
+
+import (
+	"slices"
+	"testing"
+)
+
+// shuffled returns the order a Window_shuffle hands out 0..count-1 in.
+func shuffled(count int, window_size int, seed int64) []int {
+	next_item := 0
+	window_shuffle := New_window_shuffle(func() (int, bool) {
+		if next_item == count {
+			return 0, false
+		}
+		next_item++
+		return next_item - 1, true
+	}, window_size, seed)
+
+	var order []int
+	for item, has_item := window_shuffle.Next(); has_item; item, has_item = window_shuffle.Next() {
+		order = append(order, item)
+	}
+	return order
+}
+
+func Test_window_shuffle_is_deterministic(t *testing.T) {
+	test_cases := []struct {
+		count       int
+		window_size int
+	}{
+		{0, 10},
+		{1, 10},
+		{100, 1},
+		{100, 10},
+		{100, 1000},
+	}
+
+	for _, test_case := range test_cases {
+		order := shuffled(test_case.count, test_case.window_size, 42)
+		if again := shuffled(test_case.count, test_case.window_size, 42); !slices.Equal(order, again) {
+			t.Errorf("count %d, window %d: the same seed gave %v and %v", test_case.count, test_case.window_size, order, again)
+		}
+
+		// ----| Every item is handed out exactly once
+		sorted_order := slices.Sorted(slices.Values(order))
+		for i, item := range sorted_order {
+			if item != i {
+				t.Errorf("count %d, window %d: items handed out %v, want every item of 0..%d once", test_case.count, test_case.window_size, sorted_order, test_case.count-1)
+				break
+			}
+		}
+		if len(order) != test_case.count {
+			t.Errorf("count %d, window %d: handed out %d items", test_case.count, test_case.window_size, len(order))
+		}
+	}
+}
+
+func Test_window_shuffle_window(t *testing.T) {
+
+	// ----| A window of one keeps the source order
+	if order := shuffled(10, 1, 42); !slices.Equal(order, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("window of 1 reordered the items: %v", order)
+	}
+
+	// ----| An item moves at most a window's worth of positions towards the front
+	window_size := 8
+	for position, item := range shuffled(1000, window_size, 7) {
+		if item-position >= window_size {
+			t.Errorf("item %d was handed out at position %d, more than a window (%d) early", item, position, window_size)
+		}
+	}
+
+	// ----| Different seeds give different orders
+	if slices.Equal(shuffled(100, 100, 1), shuffled(100, 100, 2)) {
+		t.Errorf("seeds 1 and 2 gave the same order")
+	}
+}
@@ -0,0 +1,80 @@
+package request_utils
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Client_config is everything the scanner's HTTP client is built from. Nothing is taken from (or written to)
+// http.DefaultTransport or http.DefaultClient.
+type Client_config struct {
+	Redirect_mode           string
+	Allow_insecure_requests bool          // Skip TLS certificate verification
+	Connect_timeout         time.Duration // TCP connect
+	Tls_timeout             time.Duration // TLS handshake
+	Total_timeout           time.Duration // Whole request, from connecting to reading the last byte of the body (redirects included)
+	Max_idle_conns          int           // Idle keep-alive connections kept across every host
+	Max_idle_conns_per_host int           // Idle keep-alive connections kept per host
+	Idle_conn_timeout       time.Duration // How long an idle keep-alive connection is kept
+}
+
+// Default_client_config returns the configuration used when none is given.
+func Default_client_config() Client_config {
+	return Client_config{
+		Redirect_mode:           Redirect_mode_follow,
+		Connect_timeout:         10 * time.Second,
+		Tls_timeout:             10 * time.Second,
+		Total_timeout:           30 * time.Second,
+		Max_idle_conns:          100,
+		Max_idle_conns_per_host: 10,
+		Idle_conn_timeout:       90 * time.Second,
+	}
+}
+
+// default_http_client is used by requests that are sent without a client of their own.
+var default_http_client, _ = New_http_client(Default_client_config())
+
+// Max_drained_body_size is how much of an unread response body is read before closing it so its connection can be
+// reused. Larger leftovers are not worth the bandwidth, their connection is closed instead.
+const Max_drained_body_size = 256 << 10
+
+// New_http_client returns a client with its own transport built from client_config.
+func New_http_client(client_config Client_config) (*http.Client, error) {
+
+	check_redirect, redirect_policy_err := redirect_policy(client_config.Redirect_mode)
+	if redirect_policy_err != nil {
+		return nil, redirect_policy_err
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   client_config.Connect_timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: client_config.Allow_insecure_requests},
+		TLSHandshakeTimeout:   client_config.Tls_timeout,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          client_config.Max_idle_conns,
+		MaxIdleConnsPerHost:   client_config.Max_idle_conns_per_host,
+		IdleConnTimeout:       client_config.Idle_conn_timeout,
+		ExpectContinueTimeout: time.Second,
+		DisableCompression:    true, // Bodies are decoded by Decode_body so raw and decoded sizes can both be recorded
+	}
+
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: check_redirect,
+		Timeout:       client_config.Total_timeout,
+	}, nil
+}
+
+// drain_and_close reads what is left of a response body (up to Max_drained_body_size) and closes it, so the
+// connection goes back to the keep-alive pool.
+func drain_and_close(body io.ReadCloser) {
+	io.Copy(io.Discard, io.LimitReader(body, Max_drained_body_size))
+	body.Close()
+}
@@ -0,0 +1,69 @@
+package request_utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Max_decoded_body_size caps how much a compressed body may expand to, protecting against decompression bombs.
+const Max_decoded_body_size = 64 << 20
+
+// Decode_body undoes the Content-Encoding of a response body. Encodings are removed in the reverse order they were
+// applied ("gzip, br" is brotli decoded first). Setting Accept-Encoding explicitly disables Go's transparent gzip
+// handling, so every encoding we advertise has to be decoded here.
+func Decode_body(raw_body []byte, content_encoding string) ([]byte, error) {
+
+	encodings := strings.Split(content_encoding, ",")
+	decoded_body := raw_body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+
+		var decoder io.Reader
+		switch encoding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			gzip_reader, gzip_err := gzip.NewReader(bytes.NewReader(decoded_body))
+			if gzip_err != nil {
+				return nil, errors.New("An error occurred while decoding gzip body: " + gzip_err.Error())
+			}
+			defer gzip_reader.Close()
+			decoder = gzip_reader
+		case "deflate":
+			zlib_reader, zlib_err := zlib.NewReader(bytes.NewReader(decoded_body))
+			if zlib_err != nil {
+				return nil, errors.New("An error occurred while decoding deflate body: " + zlib_err.Error())
+			}
+			defer zlib_reader.Close()
+			decoder = zlib_reader
+		case "br":
+			decoder = brotli.NewReader(bytes.NewReader(decoded_body))
+		case "zstd":
+			zstd_reader, zstd_err := zstd.NewReader(bytes.NewReader(decoded_body))
+			if zstd_err != nil {
+				return nil, errors.New("An error occurred while decoding zstd body: " + zstd_err.Error())
+			}
+			defer zstd_reader.Close()
+			decoder = zstd_reader
+		default:
+			return nil, errors.New("Unsupported Content-Encoding: " + encoding)
+		}
+
+		decoded_layer, decode_err := io.ReadAll(io.LimitReader(decoder, Max_decoded_body_size+1))
+		if decode_err != nil {
+			return nil, errors.New("An error occurred while decoding " + encoding + " body: " + decode_err.Error())
+		}
+		if len(decoded_layer) > Max_decoded_body_size {
+			return nil, errors.New("Decoded " + encoding + " body exceeds the maximum decoded body size")
+		}
+		decoded_body = decoded_layer
+	}
+	return decoded_body, nil
+}
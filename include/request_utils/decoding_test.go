@@ -0,0 +1,93 @@
+package request_utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encode compresses body with a single Content-Encoding.
+func encode(t *testing.T, body []byte, encoding string) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	var encoder io.WriteCloser
+	switch encoding {
+	case "gzip":
+		encoder = gzip.NewWriter(&encoded)
+	case "deflate":
+		encoder = zlib.NewWriter(&encoded)
+	case "br":
+		encoder = brotli.NewWriter(&encoded)
+	case "zstd":
+		zstd_encoder, zstd_err := zstd.NewWriter(&encoded)
+		if zstd_err != nil {
+			t.Fatalf("zstd.NewWriter returned error: %s", zstd_err)
+		}
+		encoder = zstd_encoder
+	default:
+		t.Fatalf("unknown encoding: %s", encoding)
+	}
+	encoder.Write(body)
+	encoder.Close()
+	return encoded.Bytes()
+}
+
+func Test_decode_body(t *testing.T) {
+	body := []byte("<html><title>Admin</title>admin panel</html>")
+	test_cases := []struct {
+		content_encoding string
+		raw_body         []byte
+	}{
+		{"", body},
+		{"identity", body},
+		{"gzip", encode(t, body, "gzip")},
+		{"x-gzip", encode(t, body, "gzip")},
+		{"GZIP", encode(t, body, "gzip")},
+		{"deflate", encode(t, body, "deflate")},
+		{"br", encode(t, body, "br")},
+		{"zstd", encode(t, body, "zstd")},
+
+		// ----| Layers are removed in the reverse order they were applied
+		{"gzip, br", encode(t, encode(t, body, "gzip"), "br")},
+		{"deflate,zstd", encode(t, encode(t, body, "deflate"), "zstd")},
+	}
+
+	for _, test_case := range test_cases {
+		decoded_body, decode_err := Decode_body(test_case.raw_body, test_case.content_encoding)
+		if decode_err != nil {
+			t.Errorf("Decode_body(%q) returned error: %s", test_case.content_encoding, decode_err)
+			continue
+		}
+		if !bytes.Equal(decoded_body, body) {
+			t.Errorf("Decode_body(%q) = %q, want %q", test_case.content_encoding, decoded_body, body)
+		}
+	}
+}
+
+func Test_decode_body_errors(t *testing.T) {
+	test_cases := []struct {
+		content_encoding string
+		raw_body         []byte
+	}{
+		{"compress", []byte("anything")},
+		{"gzip", []byte("not gzip")},
+		{"br, gzip", encode(t, []byte("only gzip"), "br")},
+		{"gzip", encode(t, make([]byte, Max_decoded_body_size+1), "gzip")},
+	}
+
+	for _, test_case := range test_cases {
+		if _, decode_err := Decode_body(test_case.raw_body, test_case.content_encoding); decode_err == nil {
+			t.Errorf("Decode_body(%q) did not return an error", test_case.content_encoding)
+		}
+	}
+
+	// ----| A body of exactly Max_decoded_body_size is still decoded
+	if _, decode_err := Decode_body(encode(t, make([]byte, Max_decoded_body_size), "gzip"), "gzip"); decode_err != nil {
+		t.Errorf("a body of Max_decoded_body_size bytes was rejected: %s", decode_err)
+	}
+}
@@ -0,0 +1,91 @@
+package request_utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// ----| Kinds of network errors a request can fail with
+const (
+	Error_class_timeout = "timeout"
+	Error_class_reset   = "reset"
+	Error_class_refused = "refused"
+	Error_class_tls     = "tls"
+	Error_class_dns     = "dns"
+	Error_class_other   = "other"
+)
+
+// Request_error is returned when a request could not be sent or its response could not be read. Class tells the
+// caller whether trying again may help.
+type Request_error struct {
+	Class   string
+	Message string
+	Cause   error
+}
+
+func (request_err *Request_error) Error() string {
+	return request_err.Message
+}
+
+func (request_err *Request_error) Unwrap() error {
+	return request_err.Cause
+}
+
+// new_request_error wraps cause with message, classifying it.
+func new_request_error(message string, cause error) *Request_error {
+	return &Request_error{Class: Classify_error(cause), Message: message + "\n" + cause.Error(), Cause: cause}
+}
+
+// Classify_error returns the Error_class_* of a request error.
+func Classify_error(err error) string {
+
+	var dns_err *net.DNSError
+	if errors.As(err, &dns_err) {
+		if dns_err.IsTimeout {
+			return Error_class_timeout
+		}
+		return Error_class_dns
+	}
+
+	var net_err net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &net_err) && net_err.Timeout()) {
+		return Error_class_timeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return Error_class_refused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return Error_class_reset
+	}
+
+	var record_header_err tls.RecordHeaderError
+	var alert_err tls.AlertError
+	var certificate_verification_err *tls.CertificateVerificationError
+	var unknown_authority_err x509.UnknownAuthorityError
+	var hostname_err x509.HostnameError
+	var certificate_invalid_err x509.CertificateInvalidError
+	if errors.As(err, &record_header_err) || errors.As(err, &alert_err) || errors.As(err, &certificate_verification_err) ||
+		errors.As(err, &unknown_authority_err) || errors.As(err, &hostname_err) || errors.As(err, &certificate_invalid_err) ||
+		strings.Contains(err.Error(), "tls: ") {
+		return Error_class_tls
+	}
+	return Error_class_other
+}
+
+// Is_transient reports whether a request that failed with error_class may succeed when it is sent again.
+func Is_transient(error_class string) bool {
+	return error_class == Error_class_timeout || error_class == Error_class_reset || error_class == Error_class_refused
+}
+
+// Is_scheme_mismatch reports whether a request failed because the port does not speak TLS: an https request sent to a
+// plain HTTP (or other non TLS) port.
+func Is_scheme_mismatch(err error) bool {
+	var record_header_err tls.RecordHeaderError
+	return errors.As(err, &record_header_err) || strings.Contains(err.Error(), "server gave HTTP response to HTTPS client")
+}
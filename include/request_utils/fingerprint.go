@@ -0,0 +1,114 @@
+package request_utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"vhost-scout/include/compare_utils"
+	"vhost-scout/include/normalize_utils"
+)
+
+// ResponseFingerprint holds everything that is kept of a response once its body has been read. Body derived
+// values (hashes, counts, title) and the Location header are taken from the normalized response.
+type ResponseFingerprint struct {
+	Status_code      int      `json:"status_code"`
+	Content_length   int64    `json:"content_length"`   // Length of the decoded body in bytes
+	Raw_length       int64    `json:"raw_length"`       // Length of the body as received, before Content-Encoding was undone
+	Content_encoding string   `json:"content_encoding"` // Content-Encoding header, suffixed with " (undecoded)" when decoding failed
+	Word_count       int      `json:"word_count"`
+	Line_count       int      `json:"line_count"`
+	Title            string   `json:"title"`
+	Location         string   `json:"location"`
+	Content_type     string   `json:"content_type"`
+	Server           string   `json:"server"`
+	Set_cookie_names []string `json:"set_cookie_names"`
+	Body_md5         string   `json:"body_md5"`
+	Body_simhash     uint64   `json:"body_simhash"`
+	Redirect_chain   []string `json:"redirect_chain"` // Hops followed before the final response, see Redirect_chain
+
+	// ----| Raw response kept in memory for match and filter rules until Without_raw_response drops it, not persisted
+	Body    []byte      `json:"-"`
+	Headers http.Header `json:"-"`
+}
+
+// Without_raw_response returns the fingerprint without the raw body and headers. Fingerprints that are kept (hits and
+// baseline samples) drop them once the match and filter rules have been applied, bodies can be up to
+// Max_decoded_body_size bytes.
+func (fingerprint ResponseFingerprint) Without_raw_response() ResponseFingerprint {
+	fingerprint.Body = nil
+	fingerprint.Headers = nil
+	return fingerprint
+}
+
+var html_title_regex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// Gen_response_fingerprint reads the response body, up to Max_decoded_body_size bytes of it, and reduces the response
+// to a ResponseFingerprint. Longer bodies are fingerprinted from their first Max_decoded_body_size bytes.
+func Gen_response_fingerprint(response *http.Response, probed_host string, normalizer *normalize_utils.Normalizer) (ResponseFingerprint, error) {
+	response_body_bytes, io_read_err := io.ReadAll(io.LimitReader(response.Body, Max_decoded_body_size))
+	if io_read_err != nil {
+		return ResponseFingerprint{}, &Request_error{Class: Classify_error(io_read_err), Message: "An error occurred while reading response body: " + io_read_err.Error(), Cause: io_read_err}
+	}
+
+	// ----| Undo Content-Encoding, bodies that cannot be decoded are fingerprinted as received
+	raw_length := int64(len(response_body_bytes))
+	content_encoding := response.Header.Get("Content-Encoding")
+	decoded_body_bytes, decode_err := Decode_body(response_body_bytes, content_encoding)
+	if decode_err == nil {
+		response_body_bytes = decoded_body_bytes
+	} else {
+		content_encoding += " (undecoded)"
+	}
+
+	fingerprint := ResponseFingerprint{
+		Status_code:      response.StatusCode,
+		Content_length:   int64(len(response_body_bytes)),
+		Raw_length:       raw_length,
+		Content_encoding: content_encoding,
+		Location:         response.Header.Get("Location"),
+		Content_type:     response.Header.Get("Content-Type"),
+		Server:           response.Header.Get("Server"),
+		Body:             response_body_bytes,
+		Headers:          response.Header,
+		Redirect_chain:   Redirect_chain(response),
+	}
+
+	// ----| Normalize body and Location before fingerprinting
+	if normalizer != nil {
+		host_pattern := normalize_utils.Host_pattern(probed_host)
+		response_body_bytes = normalizer.Normalize(response_body_bytes, host_pattern)
+		fingerprint.Location = string(normalizer.Normalize([]byte(fingerprint.Location), host_pattern))
+	}
+
+	response_body_md5_hash := md5.Sum(response_body_bytes)
+	fingerprint.Body_md5 = hex.EncodeToString(response_body_md5_hash[:])
+	fingerprint.Body_simhash = compare_utils.Simhash(response_body_bytes)
+	fingerprint.Word_count = len(bytes.Fields(response_body_bytes))
+	fingerprint.Line_count = bytes.Count(response_body_bytes, []byte("\n")) + 1
+	if len(response_body_bytes) == 0 {
+		fingerprint.Line_count = 0
+	}
+
+	if title_match := html_title_regex.FindSubmatch(response_body_bytes); title_match != nil {
+		fingerprint.Title = strings.Join(strings.Fields(html.UnescapeString(string(title_match[1]))), " ")
+	}
+
+	// ----| Keep only cookie names, values are almost always volatile
+	for _, cookie := range response.Cookies() {
+		fingerprint.Set_cookie_names = append(fingerprint.Set_cookie_names, cookie.Name)
+	}
+	sort.Strings(fingerprint.Set_cookie_names)
+
+	return fingerprint, nil
+}
+
+// Cookie_names_key joins the Set-Cookie names into a single comparable string.
+func (fingerprint ResponseFingerprint) Cookie_names_key() string {
+	return strings.Join(fingerprint.Set_cookie_names, ",")
+}
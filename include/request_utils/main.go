@@ -1,12 +1,13 @@
 package request_utils
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"context"
 	"errors"
-	"io"
 	"math/rand"
 	"net/http"
+	"time"
+	"vhost-scout/include/normalize_utils"
+	"vhost-scout/include/schedule_utils"
 )
 
 // weightedRandom selects a random item based on probabilities.
@@ -70,39 +71,104 @@ func Generate_random_request_headers() http.Header {
 	return headers
 }
 
-func Send_request_with_spoofed_host_header(target string, vhost string) (string, http.Response, error) {
+// Probe_config holds the settings shared by every request sent to a target.
+type Probe_config struct {
+	Client     *http.Client                // Built with New_http_client, defaults to a client built from Default_client_config
+	Normalizer *normalize_utils.Normalizer // When not nil responses are normalized before they are fingerprinted
+	Probe_mode string                      // One of Probe_modes, defaults to Probe_mode_host
+	Sni_front  string                      // SNI sent in Probe_mode_mismatch
+
+	Injection_vector string // One of Injection_vectors, defaults to Injection_vector_host
+
+	Header_profile *Header_profile // Headers sent with every request, random headers are generated per request when nil
+
+	Concurrency_limiter *schedule_utils.Concurrency_limiter // Shared by every target, nil disables the limits
+	Rate_limiter        *schedule_utils.Rate_limiter        // Shared by every target, nil disables rate limiting and jitter
+
+	Throttle_status_codes map[int]bool // Status codes that mean the target is throttling us, Default_throttle_status_codes when nil
+	Baseline_status_codes map[int]bool // Status codes of the target's calibrated baseline, nil while it is being calibrated
+}
+
+// Pace_request blocks until a request to host may be sent: it first takes a concurrency slot and only then waits for
+// the rate limiter, so requests queued behind a full slot cannot pile up tokens and go out back to back once the slot
+// frees up. The returned function releases the slot, it must be called once the request is done. host is a hostname or
+// IP without port, so every port of a machine shares its limits.
+func Pace_request(ctx context.Context, probe_config Probe_config, host string) (func(), error) {
+	if acquire_err := probe_config.Concurrency_limiter.Acquire(ctx, host); acquire_err != nil {
+		return nil, acquire_err
+	}
+	release := func() { probe_config.Concurrency_limiter.Release(host) }
+	if rate_limit_err := probe_config.Rate_limiter.Wait(ctx, host); rate_limit_err != nil {
+		release()
+		return nil, rate_limit_err
+	}
+	return release, nil
+}
+
+// Send_request_with_spoofed_host_header requests target with the Host header set to vhost and returns the fingerprint of
+// the response, which is closed before it returns. Once ctx is done no new request is sent and ctx's error is returned, a request that is already on the wire is left
+// to finish (bounded by the client's timeouts).
+func Send_request_with_spoofed_host_header(ctx context.Context, target string, vhost string, probe_config Probe_config) (ResponseFingerprint, error) {
 
 	// ----| Build request so we can spoof Host header
 	spoofed_req, new_http_req_err := http.NewRequest("GET", target, nil)
 	if new_http_req_err != nil {
-		return "", http.Response{}, errors.New("Error occurred while attempting to build request to: " + target + "with Host header: " + vhost + "\n" + new_http_req_err.Error())
+		return ResponseFingerprint{}, errors.New("Error occurred while attempting to build request to: " + target + "with Host header: " + vhost + "\n" + new_http_req_err.Error())
 	}
 
 	// ----| Set Request Headers
-	spoofed_req_headers := Generate_random_request_headers() // Generate Random Request Headers
-	spoofed_req.Header = spoofed_req_headers
+	if probe_config.Header_profile != nil {
+		spoofed_req.Header = probe_config.Header_profile.Headers.Clone()
+	} else {
+		spoofed_req.Header = Generate_random_request_headers() // Generate Random Request Headers
+	}
 	// Set additional headers here as needed
-	spoofed_req.Host = vhost // Spoof host header
+	spoofed_host, spoofed_sni := probe_host_and_sni(probe_config, vhost)
+	inject_vhost(spoofed_req, probe_config.Injection_vector, spoofed_host) // Spoof host header (or the selected alternative)
 
-	// ----| Make request with spoofed Host header
-	resp_to_spoofed_req, spoofed_req_err := http.DefaultClient.Do(spoofed_req)
+	// ----| Make request with spoofed Host header (and SNI)
+	http_client := probe_config.Client
+	if http_client == nil {
+		http_client = default_http_client
+	}
+	if spoofed_sni != "" && spoofed_req.URL.Scheme == "https" {
+		sni_client, sni_client_err := client_with_sni(http_client, spoofed_sni)
+		if sni_client_err != nil {
+			return ResponseFingerprint{}, errors.New("An error occurred while preparing a request to: " + target + " with SNI: " + spoofed_sni + "\n" + sni_client_err.Error())
+		}
+		http_client = sni_client
+		defer sni_client.CloseIdleConnections()
+	}
+	release, pace_err := Pace_request(ctx, probe_config, spoofed_req.URL.Hostname())
+	if pace_err != nil {
+		return ResponseFingerprint{}, pace_err
+	}
+	defer release()
+	resp_to_spoofed_req, spoofed_req_err := http_client.Do(spoofed_req)
 	if spoofed_req_err != nil {
-		return "", http.Response{}, errors.New("An error occurred while making a spoofed request to: " + target + " with Host header: " + vhost + "\n" + spoofed_req_err.Error())
+		return ResponseFingerprint{}, new_request_error("An error occurred while making a spoofed request to: "+target+" with Host header: "+vhost, spoofed_req_err)
 	}
-
-	// ----| Generate md5 hash from baseline_resp body
-	resp_to_spoofed_req_md5_hash, hash_gen_err := gen_response_body_md5(resp_to_spoofed_req.Body)
-	if hash_gen_err != nil {
-		return "", http.Response{}, errors.New("Error occurred while attempting to generate md5 hash of the baseline response body while processing target: " + target)
+	defer drain_and_close(resp_to_spoofed_req.Body)
+
+	// ----| Pause the host instead of fingerprinting responses that ask us to slow down
+	if is_throttling_response(resp_to_spoofed_req, probe_config.Throttle_status_codes, probe_config.Baseline_status_codes) {
+		retry_after := Parse_retry_after(resp_to_spoofed_req.Header.Get("Retry-After"), time.Now())
+		backoff, started_backoff := probe_config.Rate_limiter.Back_off(spoofed_req.URL.Hostname(), retry_after)
+		return ResponseFingerprint{}, &Throttled_error{
+			Target:          target,
+			Vhost:           vhost,
+			Status_code:     resp_to_spoofed_req.StatusCode,
+			Retry_after:     retry_after,
+			Backoff:         backoff,
+			Started_backoff: started_backoff,
+		}
 	}
-	return resp_to_spoofed_req_md5_hash, *resp_to_spoofed_req, nil
-}
+	probe_config.Rate_limiter.Reset_backoff(spoofed_req.URL.Hostname())
 
-func gen_response_body_md5(response_body io.ReadCloser) (string, error) {
-	response_body_md5_hash := md5.New()
-	_, io_copy_err := io.Copy(response_body_md5_hash, response_body)
-	if io_copy_err != nil {
-		return "", errors.New("An error occurred while generating md5 hash of response body: " + io_copy_err.Error())
+	// ----| Fingerprint response
+	resp_to_spoofed_req_fingerprint, fingerprint_gen_err := Gen_response_fingerprint(resp_to_spoofed_req, vhost, probe_config.Normalizer)
+	if fingerprint_gen_err != nil {
+		return ResponseFingerprint{}, new_request_error("Error occurred while attempting to fingerprint the response from: "+target+" with Host header: "+vhost, fingerprint_gen_err)
 	}
-	return hex.EncodeToString(response_body_md5_hash.Sum(nil)), nil
+	return resp_to_spoofed_req_fingerprint, nil
 }
@@ -0,0 +1,75 @@
+package request_utils
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"vhost-scout/include/file_utils"
+)
+
+// ----| How often a new header profile is chosen
+const (
+	Header_profile_scope_target = "target" // A profile is chosen for each target
+	Header_profile_scope_scan   = "scan"   // A single profile is used for the whole scan
+)
+
+// Header_profile is a consistent set of request headers. Using the same profile for the baseline and every candidate
+// of a target keeps servers that vary on Accept, Accept-Language or User-Agent from producing false hits.
+type Header_profile struct {
+	Name    string
+	Headers http.Header
+}
+
+// Gen_random_header_profile builds a profile from the same weighted pools Generate_random_request_headers draws from.
+func Gen_random_header_profile() Header_profile {
+	return Header_profile{Name: "random", Headers: Generate_random_request_headers()}
+}
+
+// Pick_header_profile returns a random profile from header_profiles, or a freshly generated one when there are none.
+func Pick_header_profile(header_profiles []Header_profile) Header_profile {
+	if len(header_profiles) == 0 {
+		return Gen_random_header_profile()
+	}
+	return header_profiles[rand.Intn(len(header_profiles))]
+}
+
+// Load_header_profiles reads header profiles from a file. Every profile starts with a "[name]" line followed by
+// "Header: value" lines. Blank lines and lines starting with # are ignored, e.g.
+//
+//	[chrome-en]
+//	User-Agent: Mozilla/5.0 (Windows NT 10.0; Win64; x64) ...
+//	Accept-Language: en-US,en;q=0.9
+func Load_header_profiles(profiles_path string) ([]Header_profile, error) {
+
+	lines, file_read_err := file_utils.Read_lines(profiles_path)
+	if file_read_err != nil {
+		return nil, errors.New("An error occurred while reading header profiles from file: " + profiles_path + " || Error: " + file_read_err.Error())
+	}
+
+	var header_profiles []Header_profile
+	for line_number, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// ----| Start of a new profile
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			header_profiles = append(header_profiles, Header_profile{Name: strings.TrimSpace(line[1 : len(line)-1]), Headers: http.Header{}})
+			continue
+		}
+
+		header_name, header_value, is_header := strings.Cut(line, ":")
+		if !is_header || len(header_profiles) == 0 || strings.TrimSpace(header_name) == "" {
+			return nil, errors.New("Invalid header profile line " + strconv.Itoa(line_number+1) + " of file: " + profiles_path + " (expected \"[name]\" or \"Header: value\")")
+		}
+		header_profiles[len(header_profiles)-1].Headers.Add(strings.TrimSpace(header_name), strings.TrimSpace(header_value))
+	}
+
+	if len(header_profiles) == 0 {
+		return nil, errors.New("No header profiles were found in file: " + profiles_path)
+	}
+	return header_profiles, nil
+}
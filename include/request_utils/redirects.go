@@ -0,0 +1,107 @@
+package request_utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ----| How redirects returned by a target are handled
+const (
+	Redirect_mode_follow    = "follow"    // Follow every redirect, wherever it points (Go's default behavior)
+	Redirect_mode_none      = "none"      // Never follow redirects, the redirect response itself is fingerprinted
+	Redirect_mode_same_host = "same-host" // Only follow redirects that stay on the probed vhost, re-sending them to the target
+)
+
+var Redirect_modes = []string{Redirect_mode_follow, Redirect_mode_none, Redirect_mode_same_host}
+
+// Max_redirects mirrors the limit of Go's default redirect policy.
+const Max_redirects = 10
+
+// redirect_policy returns the CheckRedirect function of a client that handles redirects according to redirect_mode.
+func redirect_policy(redirect_mode string) (func(req *http.Request, via []*http.Request) error, error) {
+	switch redirect_mode {
+	case Redirect_mode_follow:
+		return nil, nil // Go's default policy
+	case Redirect_mode_none:
+		return func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}, nil
+	case Redirect_mode_same_host:
+		return follow_same_host_redirect, nil
+	}
+	return nil, errors.New("Unknown redirect mode: " + redirect_mode + " (valid modes: " + strings.Join(Redirect_modes, ", ") + ")")
+}
+
+// follow_same_host_redirect only lets a redirect through when it points at the probed vhost. Relative redirects
+// already go back to the target with the spoofed Host header. Absolute redirects to the vhost would make Go resolve
+// the vhost name, so the request is re-pointed at the target with the Host header set to the vhost again.
+func follow_same_host_redirect(req *http.Request, via []*http.Request) error {
+
+	if len(via) >= Max_redirects {
+		return errors.New("stopped after " + strconv.Itoa(Max_redirects) + " redirects")
+	}
+
+	original_req := via[0]
+	probed_host := original_req.Host
+	if probed_host == "" {
+		probed_host = original_req.URL.Host
+	}
+
+	switch {
+	case req.URL.Host == original_req.URL.Host: // Relative redirect (or a redirect to the target itself)
+		return nil
+	case strings.EqualFold(req.URL.Hostname(), host_without_port(probed_host)):
+		target_host := original_req.URL.Host
+		if req.URL.Scheme != original_req.URL.Scheme {
+			target_host = original_req.URL.Hostname()
+			if strings.Contains(target_host, ":") {
+				target_host = "[" + target_host + "]"
+			}
+			if req.URL.Port() != "" {
+				target_host = net.JoinHostPort(original_req.URL.Hostname(), req.URL.Port())
+			}
+		}
+		req.Host = req.URL.Host
+		req.URL.Host = target_host
+		return nil
+	}
+	return http.ErrUseLastResponse
+}
+
+// host_without_port strips the port from a Host header value.
+func host_without_port(host string) string {
+	if hostname, _, split_err := net.SplitHostPort(host); split_err == nil {
+		return hostname
+	}
+	return strings.Trim(host, "[]")
+}
+
+// Redirect_chain lists every hop that led to response as "<status code> <url>" entries, ending with the final response.
+// It is empty when no redirect was followed.
+func Redirect_chain(response *http.Response) []string {
+
+	var redirect_chain []string
+	for hop := response; hop != nil; {
+		hop_url := ""
+		if hop.Request != nil {
+			hop_url = hop.Request.URL.String()
+			if hop.Request.Host != "" && hop.Request.Host != hop.Request.URL.Host {
+				hop_url += " (Host: " + hop.Request.Host + ")"
+			}
+		}
+		redirect_chain = append([]string{strconv.Itoa(hop.StatusCode) + " " + hop_url}, redirect_chain...)
+
+		if hop.Request == nil {
+			break
+		}
+		hop = hop.Request.Response
+	}
+
+	if len(redirect_chain) == 1 {
+		return nil
+	}
+	return redirect_chain
+}
@@ -0,0 +1,94 @@
+package request_utils
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ----| Where the candidate vhost is placed when probing a target
+const (
+	Probe_mode_host     = "host"     // Host header set to the candidate, SNI left to the target's hostname
+	Probe_mode_sni      = "sni"      // SNI set to the candidate, Host header left to the target's host
+	Probe_mode_both     = "both"     // SNI and Host header both set to the candidate
+	Probe_mode_mismatch = "mismatch" // SNI set to a fixed front name, Host header set to the candidate (domain fronting)
+)
+
+var Probe_modes = []string{Probe_mode_host, Probe_mode_sni, Probe_mode_both, Probe_mode_mismatch}
+
+// Parse_probe_modes parses a comma separated list of probe modes.
+func Parse_probe_modes(probe_modes_list string, sni_front string) ([]string, error) {
+
+	var probe_modes []string
+	for _, probe_mode := range strings.Split(probe_modes_list, ",") {
+		probe_mode = strings.ToLower(strings.TrimSpace(probe_mode))
+		if probe_mode == "" {
+			continue
+		}
+
+		is_known_mode := false
+		for _, known_mode := range Probe_modes {
+			if probe_mode == known_mode {
+				is_known_mode = true
+			}
+		}
+		if !is_known_mode {
+			return nil, errors.New("Unknown probe mode: " + probe_mode + " (valid modes: " + strings.Join(Probe_modes, ", ") + ")")
+		}
+		if probe_mode == Probe_mode_mismatch && sni_front == "" {
+			return nil, errors.New("The mismatch probe mode requires an SNI front name")
+		}
+		probe_modes = append(probe_modes, probe_mode)
+	}
+
+	if len(probe_modes) == 0 {
+		return nil, errors.New("At least one probe mode is required")
+	}
+	return probe_modes, nil
+}
+
+// Sets_sni reports whether a probe mode changes the TLS SNI, which is only meaningful for https targets.
+func Sets_sni(probe_mode string) bool {
+	return probe_mode == Probe_mode_sni || probe_mode == Probe_mode_both || probe_mode == Probe_mode_mismatch
+}
+
+// probe_host_and_sni returns the Host header ("" keeps the target's host) and SNI ("" keeps the default) for a probe.
+func probe_host_and_sni(probe_config Probe_config, vhost string) (string, string) {
+	switch probe_config.Probe_mode {
+	case Probe_mode_sni:
+		return "", host_without_port(vhost)
+	case Probe_mode_both:
+		return vhost, host_without_port(vhost)
+	case Probe_mode_mismatch:
+		return vhost, probe_config.Sni_front
+	}
+	return vhost, ""
+}
+
+// client_with_sni returns a copy of http_client whose TLS handshakes send server_name as SNI. Keep-alives are
+// disabled on the copy so a connection negotiated for one name is never reused for another. Certificates are not
+// verified: the SNI is a random or candidate name the certificate cannot be expected to cover, and these probes are
+// after the routing the name triggers, not the identity of the server.
+func client_with_sni(http_client *http.Client, server_name string) (*http.Client, error) {
+
+	base_transport, is_http_transport := http_client.Transport.(*http.Transport)
+	if http_client.Transport == nil {
+		base_transport, is_http_transport = default_http_client.Transport.(*http.Transport)
+	}
+	if !is_http_transport {
+		return nil, errors.New("Cannot set the SNI on a client that does not use an *http.Transport")
+	}
+
+	sni_transport := base_transport.Clone()
+	if sni_transport.TLSClientConfig == nil {
+		sni_transport.TLSClientConfig = &tls.Config{}
+	}
+	sni_transport.TLSClientConfig.ServerName = server_name
+	sni_transport.TLSClientConfig.InsecureSkipVerify = true
+	sni_transport.DisableKeepAlives = true
+
+	sni_client := *http_client
+	sni_client.Transport = sni_transport
+	return &sni_client, nil
+}
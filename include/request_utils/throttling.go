@@ -0,0 +1,86 @@
+package request_utils
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default_throttle_status_codes are the status codes treated as "slow down" when no others are configured.
+var Default_throttle_status_codes = map[int]bool{http.StatusTooManyRequests: true, http.StatusServiceUnavailable: true}
+
+// Ambiguous_throttle_status_codes are throttle status codes that some targets also answer for any unknown vhost (load
+// balancers and ingress default backends answer 503). Without a Retry-After header they only count as throttling once
+// the target's baseline is calibrated and answers with a different status code.
+var Ambiguous_throttle_status_codes = map[int]bool{http.StatusServiceUnavailable: true}
+
+// Throttled_error is returned instead of a fingerprint when the target answered with a throttling response. The host
+// has already been paused by the rate limiter when it is returned.
+type Throttled_error struct {
+	Target          string
+	Vhost           string
+	Status_code     int
+	Retry_after     time.Duration // Zero when the response did not carry a (valid) Retry-After header
+	Backoff         time.Duration // How long the host is paused for
+	Started_backoff bool          // Whether this response started the pause, false when the host was already paused
+}
+
+func (throttled_err *Throttled_error) Error() string {
+	return "Throttled by: " + throttled_err.Target + " with Host header: " + throttled_err.Vhost + " (Status Code: " + strconv.Itoa(throttled_err.Status_code) + ", Retry-After: " + throttled_err.Retry_after.String() + ")"
+}
+
+// Parse_throttle_status_codes parses a comma separated list of status codes that mean the target is throttling us.
+func Parse_throttle_status_codes(throttle_status_codes_list string) (map[int]bool, error) {
+
+	throttle_status_codes := map[int]bool{}
+	for _, status_code := range strings.Split(throttle_status_codes_list, ",") {
+		status_code = strings.TrimSpace(status_code)
+		if status_code == "" {
+			continue
+		}
+		parsed_status_code, atoi_err := strconv.Atoi(status_code)
+		if atoi_err != nil || parsed_status_code < 100 || parsed_status_code > 599 {
+			return nil, errors.New("Invalid throttle status code: " + status_code)
+		}
+		throttle_status_codes[parsed_status_code] = true
+	}
+	return throttle_status_codes, nil
+}
+
+// is_throttling_response reports whether a response asks us to slow down: an error response carrying a Retry-After
+// header, or a throttle status code. An ambiguous throttle status code without Retry-After only counts when
+// baseline_status_codes are known and do not include it.
+func is_throttling_response(response *http.Response, throttle_status_codes map[int]bool, baseline_status_codes map[int]bool) bool {
+	if response.StatusCode >= 400 && response.Header.Get("Retry-After") != "" {
+		return true
+	}
+	if throttle_status_codes == nil {
+		throttle_status_codes = Default_throttle_status_codes
+	}
+	if !throttle_status_codes[response.StatusCode] {
+		return false
+	}
+	if Ambiguous_throttle_status_codes[response.StatusCode] {
+		return baseline_status_codes != nil && !baseline_status_codes[response.StatusCode]
+	}
+	return true
+}
+
+// Parse_retry_after returns the delay a Retry-After header asks for, given either in seconds or as an HTTP date.
+// Zero is returned for missing or invalid values.
+func Parse_retry_after(retry_after string, now time.Time) time.Duration {
+
+	retry_after = strings.TrimSpace(retry_after)
+	if retry_after == "" {
+		return 0
+	}
+	if seconds, atoi_err := strconv.Atoi(retry_after); atoi_err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if retry_at, parse_time_err := http.ParseTime(retry_after); parse_time_err == nil && retry_at.After(now) {
+		return retry_at.Sub(now)
+	}
+	return 0
+}
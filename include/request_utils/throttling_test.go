@@ -0,0 +1,69 @@
+package request_utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_parse_retry_after(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	test_cases := []struct {
+		retry_after string
+		delay       time.Duration
+	}{
+		{"", 0},
+		{"  ", 0},
+		{"0", 0},
+		{"120", 120 * time.Second},
+		{" 5 ", 5 * time.Second},
+		{"-5", 0},
+		{"soon", 0},
+		{"1.5", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-90 * time.Second).Format(http.TimeFormat), 0},
+		{"Sun, 18 Oct 2026 12:00:30 GMT", 30 * time.Second},
+	}
+
+	for _, test_case := range test_cases {
+		if delay := Parse_retry_after(test_case.retry_after, now); delay != test_case.delay {
+			t.Errorf("Parse_retry_after(%q) = %s, want %s", test_case.retry_after, delay, test_case.delay)
+		}
+	}
+}
+
+func Test_is_throttling_response(t *testing.T) {
+	test_cases := []struct {
+		name                  string
+		status_code           int
+		retry_after           string
+		throttle_status_codes map[int]bool
+		baseline_status_codes map[int]bool
+		is_throttling         bool
+	}{
+		{"429", http.StatusTooManyRequests, "", nil, nil, true},
+		{"200", http.StatusOK, "", nil, map[int]bool{200: true}, false},
+		{"error with Retry-After", http.StatusForbidden, "30", nil, nil, true},
+		{"success with Retry-After", http.StatusOK, "30", nil, nil, false},
+		{"configured status code", http.StatusForbidden, "", map[int]bool{403: true}, map[int]bool{200: true}, true},
+		{"429 not configured", http.StatusTooManyRequests, "", map[int]bool{403: true}, nil, false},
+
+		// ----| A 503 without Retry-After is only throttling when the calibrated baseline answers something else
+		{"503 while calibrating", http.StatusServiceUnavailable, "", nil, nil, false},
+		{"503 answered by the baseline", http.StatusServiceUnavailable, "", nil, map[int]bool{503: true}, false},
+		{"503 differing from the baseline", http.StatusServiceUnavailable, "", nil, map[int]bool{200: true, 404: true}, true},
+		{"503 with Retry-After while calibrating", http.StatusServiceUnavailable, "5", nil, nil, true},
+		{"503 with Retry-After answered by the baseline", http.StatusServiceUnavailable, "5", nil, map[int]bool{503: true}, true},
+		{"503 not configured", http.StatusServiceUnavailable, "", map[int]bool{429: true}, map[int]bool{200: true}, false},
+	}
+
+	for _, test_case := range test_cases {
+		response := &http.Response{StatusCode: test_case.status_code, Header: http.Header{}}
+		if test_case.retry_after != "" {
+			response.Header.Set("Retry-After", test_case.retry_after)
+		}
+		if is_throttling := is_throttling_response(response, test_case.throttle_status_codes, test_case.baseline_status_codes); is_throttling != test_case.is_throttling {
+			t.Errorf("%s: is_throttling_response = %t, want %t", test_case.name, is_throttling, test_case.is_throttling)
+		}
+	}
+}
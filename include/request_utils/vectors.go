@@ -0,0 +1,95 @@
+package request_utils
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ----| How the candidate vhost is injected into the HTTP request
+const (
+	Injection_vector_host             = "host"             // Host header
+	Injection_vector_x_forwarded_host = "x-forwarded-host" // X-Forwarded-Host header, Host header left to the target's host
+	Injection_vector_x_host           = "x-host"           // X-Host header, Host header left to the target's host
+	Injection_vector_x_original_host  = "x-original-host"  // X-Original-Host header, Host header left to the target's host
+	Injection_vector_forwarded        = "forwarded"        // Forwarded: host=<vhost> header, Host header left to the target's host
+	Injection_vector_absolute_uri     = "absolute-uri"     // Absolute-form request line (GET http://<vhost>/ HTTP/1.1) and Host header
+)
+
+var Injection_vectors = []string{
+	Injection_vector_host,
+	Injection_vector_x_forwarded_host,
+	Injection_vector_x_host,
+	Injection_vector_x_original_host,
+	Injection_vector_forwarded,
+	Injection_vector_absolute_uri,
+}
+
+// injection_vector_headers maps header based vectors to the header they set.
+var injection_vector_headers = map[string]string{
+	Injection_vector_x_forwarded_host: "X-Forwarded-Host",
+	Injection_vector_x_host:           "X-Host",
+	Injection_vector_x_original_host:  "X-Original-Host",
+}
+
+// Parse_injection_vectors parses a comma separated list of injection vectors, "all" selects every vector.
+func Parse_injection_vectors(injection_vectors_list string) ([]string, error) {
+
+	if strings.TrimSpace(injection_vectors_list) == "all" {
+		return Injection_vectors, nil
+	}
+
+	var injection_vectors []string
+	for _, injection_vector := range strings.Split(injection_vectors_list, ",") {
+		injection_vector = strings.ToLower(strings.TrimSpace(injection_vector))
+		if injection_vector == "" {
+			continue
+		}
+
+		is_known_vector := false
+		for _, known_vector := range Injection_vectors {
+			if injection_vector == known_vector {
+				is_known_vector = true
+			}
+		}
+		if !is_known_vector {
+			return nil, errors.New("Unknown injection vector: " + injection_vector + " (valid vectors: " + strings.Join(Injection_vectors, ", ") + ", all)")
+		}
+		injection_vectors = append(injection_vectors, injection_vector)
+	}
+
+	if len(injection_vectors) == 0 {
+		return nil, errors.New("At least one injection vector is required")
+	}
+	return injection_vectors, nil
+}
+
+// inject_vhost places vhost into req using injection_vector. An empty vhost leaves the request untouched.
+func inject_vhost(req *http.Request, injection_vector string, vhost string) {
+
+	if vhost == "" {
+		return
+	}
+
+	switch injection_vector {
+	case Injection_vector_forwarded:
+		forwarded_host := vhost
+		if strings.ContainsAny(vhost, ":[]") { // Not a valid token, RFC 7239 requires a quoted-string
+			forwarded_host = `"` + vhost + `"`
+		}
+		req.Header.Set("Forwarded", "host="+forwarded_host)
+	case Injection_vector_absolute_uri:
+		// An opaque url starting with // is written as <scheme>://<vhost><path> on the request line,
+		// while the connection is still made to req.URL.Host (the target)
+		request_path := req.URL.EscapedPath()
+		if request_path == "" {
+			request_path = "/"
+		}
+		req.URL.Opaque = "//" + vhost + request_path
+		req.Host = vhost
+	case Injection_vector_host, "":
+		req.Host = vhost
+	default:
+		req.Header.Set(injection_vector_headers[injection_vector], vhost)
+	}
+}
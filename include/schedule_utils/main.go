@@ -0,0 +1,128 @@
+package schedule_utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Concurrency_limiter bounds the number of in-flight requests, both in total and per host. Goroutines blocked on a
+// full channel are woken in the order they started waiting, so hosts take turns for free global slots and a slow host
+// can never hold more than its per host share of them. Hosts are keyed without port, the ports of a machine share its
+// slots. The slots of a host are dropped once no request to it is waiting or in flight, so scanning a large range of
+// hosts does not keep one entry per host around.
+type Concurrency_limiter struct {
+	global_slots       chan struct{}
+	per_host_slots     map[string]*t_host_slots
+	per_host_limit     int
+	per_host_slots_mux sync.Mutex
+}
+
+// t_host_slots are the slots of a single host and the number of requests waiting for or holding one of them.
+type t_host_slots struct {
+	slots chan struct{}
+	users int
+}
+
+// New_concurrency_limiter returns a limiter allowing global_limit requests in total and per_host_limit per host.
+// A limit <= 0 disables that limit.
+func New_concurrency_limiter(global_limit int, per_host_limit int) *Concurrency_limiter {
+	concurrency_limiter := &Concurrency_limiter{
+		per_host_slots: map[string]*t_host_slots{},
+		per_host_limit: per_host_limit,
+	}
+	if global_limit > 0 {
+		concurrency_limiter.global_slots = make(chan struct{}, global_limit)
+	}
+	return concurrency_limiter
+}
+
+// use_host_slots returns the slots of host and counts the caller as one of their users until done_with_host_slots.
+func (concurrency_limiter *Concurrency_limiter) use_host_slots(host string) chan struct{} {
+	if concurrency_limiter.per_host_limit <= 0 {
+		return nil
+	}
+
+	concurrency_limiter.per_host_slots_mux.Lock()
+	defer concurrency_limiter.per_host_slots_mux.Unlock()
+	host_slots, has_host_slots := concurrency_limiter.per_host_slots[host]
+	if !has_host_slots {
+		host_slots = &t_host_slots{slots: make(chan struct{}, concurrency_limiter.per_host_limit)}
+		concurrency_limiter.per_host_slots[host] = host_slots
+	}
+	host_slots.users++
+	return host_slots.slots
+}
+
+// done_with_host_slots stops counting the caller as a user of host's slots and drops them once they have no users.
+func (concurrency_limiter *Concurrency_limiter) done_with_host_slots(host string) {
+	concurrency_limiter.per_host_slots_mux.Lock()
+	defer concurrency_limiter.per_host_slots_mux.Unlock()
+	host_slots := concurrency_limiter.per_host_slots[host]
+	host_slots.users--
+	if host_slots.users == 0 {
+		delete(concurrency_limiter.per_host_slots, host)
+	}
+}
+
+// Acquire blocks until a request to host may be sent or ctx is done. Every successful Acquire must be followed by a
+// Release for the same host.
+func (concurrency_limiter *Concurrency_limiter) Acquire(ctx context.Context, host string) error {
+	if concurrency_limiter == nil {
+		return ctx.Err()
+	}
+	// The per host slot is taken first so requests waiting on a busy host do not sit on global slots
+	host_slots := concurrency_limiter.use_host_slots(host)
+	if host_slots != nil {
+		select {
+		case host_slots <- struct{}{}:
+		case <-ctx.Done():
+			concurrency_limiter.done_with_host_slots(host)
+			return ctx.Err()
+		}
+	}
+	if concurrency_limiter.global_slots != nil {
+		select {
+		case concurrency_limiter.global_slots <- struct{}{}:
+		case <-ctx.Done():
+			if host_slots != nil {
+				<-host_slots
+				concurrency_limiter.done_with_host_slots(host)
+			}
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Release frees the slots taken by Acquire.
+func (concurrency_limiter *Concurrency_limiter) Release(host string) {
+	if concurrency_limiter == nil {
+		return
+	}
+	if concurrency_limiter.global_slots != nil {
+		<-concurrency_limiter.global_slots
+	}
+	if concurrency_limiter.per_host_limit > 0 {
+		concurrency_limiter.per_host_slots_mux.Lock()
+		host_slots := concurrency_limiter.per_host_slots[host].slots
+		concurrency_limiter.per_host_slots_mux.Unlock()
+		<-host_slots
+		concurrency_limiter.done_with_host_slots(host)
+	}
+}
+
+// Sleep pauses for duration, returning early with ctx's error when ctx is done first.
+func Sleep(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
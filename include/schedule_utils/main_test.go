@@ -0,0 +1,44 @@
+package schedule_utils
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_concurrency_limiter_drops_idle_hosts(t *testing.T) {
+	concurrency_limiter := New_concurrency_limiter(4, 2)
+	ctx := context.Background()
+
+	for _, host := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+		if acquire_err := concurrency_limiter.Acquire(ctx, host); acquire_err != nil {
+			t.Fatalf("Acquire(%q) returned error: %s", host, acquire_err)
+		}
+	}
+	if hosts_count := len(concurrency_limiter.per_host_slots); hosts_count != 2 {
+		t.Errorf("%d hosts tracked with requests in flight, want 2", hosts_count)
+	}
+
+	concurrency_limiter.Release("10.0.0.2")
+	concurrency_limiter.Release("10.0.0.1")
+	if _, has_host_slots := concurrency_limiter.per_host_slots["10.0.0.1"]; !has_host_slots {
+		t.Errorf("slots of a host with a request in flight were dropped")
+	}
+	concurrency_limiter.Release("10.0.0.1")
+	if hosts_count := len(concurrency_limiter.per_host_slots); hosts_count != 0 {
+		t.Errorf("%d hosts tracked without requests in flight, want 0", hosts_count)
+	}
+
+	// ----| A canceled Acquire does not leave the host behind either
+	canceled_ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	concurrency_limiter.Acquire(ctx, "10.0.0.3")
+	concurrency_limiter.Acquire(ctx, "10.0.0.3")
+	if acquire_err := concurrency_limiter.Acquire(canceled_ctx, "10.0.0.3"); acquire_err == nil {
+		t.Fatalf("Acquire on a full host returned without error after ctx was canceled")
+	}
+	concurrency_limiter.Release("10.0.0.3")
+	concurrency_limiter.Release("10.0.0.3")
+	if hosts_count := len(concurrency_limiter.per_host_slots); hosts_count != 0 {
+		t.Errorf("%d hosts tracked after a canceled Acquire, want 0", hosts_count)
+	}
+}
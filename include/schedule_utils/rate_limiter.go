@@ -0,0 +1,282 @@
+package schedule_utils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ----| Distributions the jitter added to every request can be drawn from
+const (
+	Jitter_distribution_none        = "none"
+	Jitter_distribution_uniform     = "uniform"     // Evenly spread between min and max
+	Jitter_distribution_normal      = "normal"      // Centered between min and max, 99.7% of delays fall within them
+	Jitter_distribution_exponential = "exponential" // Mostly close to min with a long tail, capped at max
+)
+
+var Jitter_distributions = []string{
+	Jitter_distribution_none,
+	Jitter_distribution_uniform,
+	Jitter_distribution_normal,
+	Jitter_distribution_exponential,
+}
+
+// Jitter is a random delay added before every request so requests do not arrive at a perfectly regular pace.
+type Jitter struct {
+	Distribution string
+	Min          time.Duration
+	Max          time.Duration
+}
+
+// Parse_jitter validates a jitter distribution and its bounds.
+func Parse_jitter(distribution string, min_delay time.Duration, max_delay time.Duration) (Jitter, error) {
+
+	distribution = strings.ToLower(strings.TrimSpace(distribution))
+	is_known_distribution := false
+	for _, known_distribution := range Jitter_distributions {
+		if distribution == known_distribution {
+			is_known_distribution = true
+		}
+	}
+	if !is_known_distribution {
+		return Jitter{}, errors.New("Unknown jitter distribution: " + distribution + " (valid distributions: " + strings.Join(Jitter_distributions, ", ") + ")")
+	}
+	if min_delay < 0 || max_delay < min_delay {
+		return Jitter{}, errors.New("Invalid jitter bounds: " + min_delay.String() + " - " + max_delay.String() + " (0 <= min <= max is required)")
+	}
+	return Jitter{Distribution: distribution, Min: min_delay, Max: max_delay}, nil
+}
+
+// Delay draws a delay from the jitter distribution.
+func (jitter Jitter) Delay() time.Duration {
+
+	spread := float64(jitter.Max - jitter.Min)
+	if spread <= 0 {
+		if jitter.Distribution == Jitter_distribution_none {
+			return 0
+		}
+		return jitter.Min
+	}
+
+	offset := 0.0
+	switch jitter.Distribution {
+	case Jitter_distribution_none:
+		return 0
+	case Jitter_distribution_uniform:
+		offset = rand.Float64() * spread
+	case Jitter_distribution_normal:
+		offset = spread/2 + rand.NormFloat64()*spread/6
+	case Jitter_distribution_exponential:
+		offset = rand.ExpFloat64() * spread / 4
+	}
+	return jitter.Min + time.Duration(math.Max(0, math.Min(offset, spread)))
+}
+
+// t_token_bucket allows rate requests per second with bursts of up to burst requests. It is kept as the time at
+// which the bucket would be full again (the theoretical arrival time of the next request), which lets a request be
+// scheduled against several buckets at once without taking a token from one while it waits on another.
+type t_token_bucket struct {
+	interval            time.Duration // Time it takes to refill one token
+	burst_tolerance     time.Duration // How far ahead of the refill schedule a burst may run
+	theoretical_arrival time.Time
+}
+
+func new_token_bucket(rate float64, burst int) *t_token_bucket {
+	if rate <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / rate)
+	return &t_token_bucket{interval: interval, burst_tolerance: interval * time.Duration(max(burst, 1)-1)}
+}
+
+// earliest returns the first moment at or after now at which the bucket has a token.
+func (token_bucket *t_token_bucket) earliest(now time.Time) time.Time {
+	if token_bucket == nil {
+		return now
+	}
+	if allowed_at := token_bucket.theoretical_arrival.Add(-token_bucket.burst_tolerance); allowed_at.After(now) {
+		return allowed_at
+	}
+	return now
+}
+
+// take spends a token for a request sent at send_at.
+func (token_bucket *t_token_bucket) take(send_at time.Time) {
+	if token_bucket == nil {
+		return
+	}
+	if send_at.After(token_bucket.theoretical_arrival) {
+		token_bucket.theoretical_arrival = send_at
+	}
+	token_bucket.theoretical_arrival = token_bucket.theoretical_arrival.Add(token_bucket.interval)
+}
+
+// Backoff is how long a host that throttles us is left alone when it does not say for how long (Retry-After). The
+// pause starts at Base and doubles with every consecutive throttling response, up to Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// t_backoff_state tracks the pause of a single host.
+type t_backoff_state struct {
+	paused_until time.Time
+	consecutive  int // Number of backoffs since the host last answered without throttling
+}
+
+// Rate_limiter paces requests with a global token bucket and one token bucket per host, and adds jitter on top. Hosts
+// that throttle us are paused. One Rate_limiter is shared by every worker of every target, requests are scheduled in
+// the order they arrive. Hosts are keyed without port, so a host throttling us on one port is paused on all of them.
+// The state of a host is dropped once the last target on it calls Forget_host, a pause that is still running is kept
+// until it is over.
+type Rate_limiter struct {
+	global_bucket    *t_token_bucket
+	per_host_buckets map[string]*t_token_bucket
+	per_host_rate    float64
+	burst            int
+	jitter           Jitter
+	backoff          Backoff
+	backoff_states   map[string]*t_backoff_state
+	host_users       map[string]int // Number of targets on a host between Track_host and Forget_host
+	mutex            sync.Mutex
+}
+
+// New_rate_limiter returns a limiter allowing global_rate requests per second in total and per_host_rate per host,
+// both with bursts of up to burst requests. A rate <= 0 disables that limit.
+func New_rate_limiter(global_rate float64, per_host_rate float64, burst int, jitter Jitter, backoff Backoff) *Rate_limiter {
+	return &Rate_limiter{
+		global_bucket:    new_token_bucket(global_rate, burst),
+		per_host_buckets: map[string]*t_token_bucket{},
+		per_host_rate:    per_host_rate,
+		burst:            burst,
+		jitter:           jitter,
+		backoff:          backoff,
+		backoff_states:   map[string]*t_backoff_state{},
+		host_users:       map[string]int{},
+	}
+}
+
+// Track_host records that a target on host is being scanned, its state is kept until the matching Forget_host.
+func (rate_limiter *Rate_limiter) Track_host(host string) {
+	if rate_limiter == nil {
+		return
+	}
+
+	rate_limiter.mutex.Lock()
+	defer rate_limiter.mutex.Unlock()
+	rate_limiter.host_users[host]++
+}
+
+// Forget_host records that a target on host is done. Once no target on host is left its bucket is dropped, and so are
+// the pauses of hosts without targets that are over.
+func (rate_limiter *Rate_limiter) Forget_host(host string) {
+	if rate_limiter == nil {
+		return
+	}
+
+	rate_limiter.mutex.Lock()
+	defer rate_limiter.mutex.Unlock()
+	rate_limiter.host_users[host]--
+	if rate_limiter.host_users[host] > 0 {
+		return
+	}
+	delete(rate_limiter.host_users, host)
+	delete(rate_limiter.per_host_buckets, host)
+
+	now := time.Now()
+	for backoff_host, backoff_state := range rate_limiter.backoff_states {
+		if rate_limiter.host_users[backoff_host] == 0 && !backoff_state.paused_until.After(now) {
+			delete(rate_limiter.backoff_states, backoff_host)
+		}
+	}
+}
+
+// host_bucket returns the bucket of host, the caller must hold rate_limiter.mutex.
+func (rate_limiter *Rate_limiter) host_bucket(host string) *t_token_bucket {
+	if rate_limiter.per_host_rate <= 0 {
+		return nil
+	}
+	host_bucket, has_host_bucket := rate_limiter.per_host_buckets[host]
+	if !has_host_bucket {
+		host_bucket = new_token_bucket(rate_limiter.per_host_rate, rate_limiter.burst)
+		rate_limiter.per_host_buckets[host] = host_bucket
+	}
+	return host_bucket
+}
+
+// Wait blocks for a jitter delay and then until a request to host may be sent under both the global and the per
+// host rate, or until ctx is done. The jitter comes first so it can never push requests closer together than the
+// rates allow.
+func (rate_limiter *Rate_limiter) Wait(ctx context.Context, host string) error {
+	if rate_limiter == nil {
+		return ctx.Err()
+	}
+	if sleep_err := Sleep(ctx, rate_limiter.jitter.Delay()); sleep_err != nil {
+		return sleep_err
+	}
+
+	rate_limiter.mutex.Lock()
+	now := time.Now()
+	host_bucket := rate_limiter.host_bucket(host)
+	send_at := rate_limiter.global_bucket.earliest(now)
+	if host_send_at := host_bucket.earliest(now); host_send_at.After(send_at) {
+		send_at = host_send_at
+	}
+	if backoff_state, has_backoff_state := rate_limiter.backoff_states[host]; has_backoff_state && backoff_state.paused_until.After(send_at) {
+		send_at = backoff_state.paused_until
+	}
+	rate_limiter.global_bucket.take(send_at)
+	host_bucket.take(send_at)
+	rate_limiter.mutex.Unlock()
+
+	return Sleep(ctx, time.Until(send_at))
+}
+
+// Back_off pauses host after it answered with a throttling response, for retry_after when the host said how long to
+// wait and for an exponentially growing Backoff otherwise. It returns how long the host is paused for and whether
+// this started a new pause, throttling responses to requests sent before the pause started do not extend it.
+func (rate_limiter *Rate_limiter) Back_off(host string, retry_after time.Duration) (time.Duration, bool) {
+	if rate_limiter == nil {
+		return 0, false
+	}
+
+	rate_limiter.mutex.Lock()
+	defer rate_limiter.mutex.Unlock()
+	now := time.Now()
+	backoff_state, has_backoff_state := rate_limiter.backoff_states[host]
+	if !has_backoff_state {
+		backoff_state = &t_backoff_state{}
+		rate_limiter.backoff_states[host] = backoff_state
+	}
+	if backoff_state.paused_until.After(now) {
+		return backoff_state.paused_until.Sub(now), false
+	}
+
+	pause := retry_after
+	if pause <= 0 {
+		pause = rate_limiter.backoff.Base << min(backoff_state.consecutive, 30)
+		if rate_limiter.backoff.Max > 0 && (pause > rate_limiter.backoff.Max || pause <= 0) {
+			pause = rate_limiter.backoff.Max
+		}
+	}
+	backoff_state.consecutive++
+	backoff_state.paused_until = now.Add(pause)
+	return pause, true
+}
+
+// Reset_backoff forgets the consecutive backoffs of host once it answers without throttling again.
+func (rate_limiter *Rate_limiter) Reset_backoff(host string) {
+	if rate_limiter == nil {
+		return
+	}
+
+	rate_limiter.mutex.Lock()
+	defer rate_limiter.mutex.Unlock()
+	if backoff_state, has_backoff_state := rate_limiter.backoff_states[host]; has_backoff_state {
+		backoff_state.consecutive = 0
+	}
+}
@@ -0,0 +1,39 @@
+package schedule_utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_rate_limiter_forget_host(t *testing.T) {
+	rate_limiter := New_rate_limiter(0, 1000, 1, Jitter{Distribution: Jitter_distribution_none}, Backoff{Base: time.Hour, Max: time.Hour})
+	ctx := context.Background()
+
+	// ----| Two targets on one host, the host is throttling us
+	rate_limiter.Track_host("10.0.0.1")
+	rate_limiter.Track_host("10.0.0.1")
+	rate_limiter.Track_host("10.0.0.2")
+	rate_limiter.Wait(ctx, "10.0.0.1")
+	rate_limiter.Wait(ctx, "10.0.0.2")
+	rate_limiter.Back_off("10.0.0.1", 0)
+	rate_limiter.Back_off("10.0.0.2", time.Nanosecond)
+
+	rate_limiter.Forget_host("10.0.0.1")
+	if _, has_bucket := rate_limiter.per_host_buckets["10.0.0.1"]; !has_bucket {
+		t.Errorf("bucket of a host with a target left was dropped")
+	}
+	rate_limiter.Forget_host("10.0.0.1")
+	if _, has_bucket := rate_limiter.per_host_buckets["10.0.0.1"]; has_bucket {
+		t.Errorf("bucket of a host without targets was kept")
+	}
+	if _, has_backoff_state := rate_limiter.backoff_states["10.0.0.1"]; !has_backoff_state {
+		t.Errorf("pause of a host that is still paused was dropped")
+	}
+
+	time.Sleep(time.Millisecond)
+	rate_limiter.Forget_host("10.0.0.2")
+	if len(rate_limiter.per_host_buckets) != 0 || len(rate_limiter.host_users) != 0 || len(rate_limiter.backoff_states) != 1 {
+		t.Errorf("%d buckets, %d hosts and %d pauses left, want 0, 0 and 1", len(rate_limiter.per_host_buckets), len(rate_limiter.host_users), len(rate_limiter.backoff_states))
+	}
+}
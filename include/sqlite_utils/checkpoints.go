@@ -0,0 +1,230 @@
+package sqlite_utils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ----| Progress of a target within a scan
+const (
+	Scan_target_status_running = "running"
+	Scan_target_status_done    = "done"
+)
+
+type Scan_row struct {
+	Scan_id      string
+	Arguments    string // JSON encoded command line arguments the scan was started with
+	Vhosts_count int    // Number of candidates in the vhosts list, a resumed scan must see the same list
+	Started_at   string // RFC 3339
+
+	// ----| Checkpoint positions are only meaningful for the exact candidates and combinations the scan started with
+	Vhosts_digest string // Hash of the candidates of the vhosts list in order, empty for scans recorded by older versions
+	Combinations  string // Comma separated probe mode / injection vector combinations in order, empty for older scans
+}
+
+// Scan_target_row is the checkpoint of a single target. Combination is the index of the probe mode / injection vector
+// combination being enumerated and Position the number of candidates of the shuffled stream (shuffled with Seed) that
+// have been probed in it.
+type Scan_target_row struct {
+	Scan_id            string
+	Target             string
+	Status             string
+	Seed               int64
+	Certificate_vhosts string // Comma separated names harvested from the target's certificate
+	Combination        int
+	Position           int
+	Throttled          string // JSON encoded candidates before Position that wait to be probed again after being throttled
+}
+
+// Scan_hit_row is a hit found in a combination of a target that is not finished yet.
+type Scan_hit_row struct {
+	Scan_id     string
+	Target      string
+	Combination int
+	Hit         string // JSON encoded Table_row
+}
+
+func ensure_checkpoint_tables(database_interface *sql.DB) error {
+
+	TableExistAndCreateQuery := `
+	CREATE TABLE IF NOT EXISTS scans(
+	    scan_id TEXT PRIMARY KEY,
+		arguments TEXT NOT NULL,
+		vhosts_count INT NOT NULL,
+		started_at TEXT NOT NULL,
+		vhosts_digest TEXT NOT NULL DEFAULT '',
+		combinations TEXT NOT NULL DEFAULT ''
+	);
+	CREATE TABLE IF NOT EXISTS scan_targets(
+	    scan_id TEXT NOT NULL,
+		target TEXT NOT NULL,
+		status TEXT NOT NULL,
+		seed INT NOT NULL,
+		certificate_vhosts TEXT NOT NULL,
+		combination INT NOT NULL,
+		position INT NOT NULL,
+		throttled TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (scan_id, target)
+	);
+	CREATE TABLE IF NOT EXISTS scan_hits(
+	    scan_id TEXT NOT NULL,
+		target TEXT NOT NULL,
+		combination INT NOT NULL,
+		hit TEXT NOT NULL
+	);`
+	_, db_table_err := database_interface.Exec(TableExistAndCreateQuery)
+	if db_table_err != nil {
+		return errors.New("An error occurred while creating the checkpoint tables || Error: " + db_table_err.Error())
+	}
+	for _, column_name := range []string{"vhosts_digest", "combinations"} {
+		if add_column_err := Add_column_if_missing(database_interface, "scans", column_name, "TEXT NOT NULL DEFAULT ''"); add_column_err != nil {
+			return add_column_err
+		}
+	}
+	return Add_column_if_missing(database_interface, "scan_targets", "throttled", "TEXT NOT NULL DEFAULT ''")
+}
+
+func AddScanRowToTable(database_interface *sql.DB, scan_row Scan_row) error {
+
+	add_row_query := fmt.Sprintf(
+		"INSERT INTO scans(scan_id, arguments, vhosts_count, started_at, vhosts_digest, combinations) VALUES (%s, %s, %d, %s, %s, %s);",
+		QuoteString(scan_row.Scan_id),
+		QuoteString(scan_row.Arguments),
+		scan_row.Vhosts_count,
+		QuoteString(scan_row.Started_at),
+		QuoteString(scan_row.Vhosts_digest),
+		QuoteString(scan_row.Combinations),
+	)
+	_, db_row_err := database_interface.Exec(add_row_query)
+	if db_row_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while adding row using query: %s || Error: %s", add_row_query, db_row_err.Error()))
+	}
+	return nil
+}
+
+// Get_scan_row returns the scan with scan_id.
+func Get_scan_row(database_interface *sql.DB, scan_id string) (Scan_row, error) {
+
+	scan_row := Scan_row{}
+	scan_err := database_interface.QueryRow(fmt.Sprintf("SELECT scan_id, arguments, vhosts_count, started_at, vhosts_digest, combinations FROM scans WHERE scan_id = %s;", QuoteString(scan_id))).
+		Scan(&scan_row.Scan_id, &scan_row.Arguments, &scan_row.Vhosts_count, &scan_row.Started_at, &scan_row.Vhosts_digest, &scan_row.Combinations)
+	if scan_err == sql.ErrNoRows {
+		return Scan_row{}, errors.New("No scan with id: " + scan_id + " was found in the database")
+	}
+	if scan_err != nil {
+		return Scan_row{}, errors.New("An error occurred while reading scan: " + scan_id + " || Error: " + scan_err.Error())
+	}
+	return scan_row, nil
+}
+
+// Save_scan_target_row inserts or replaces the checkpoint of a target.
+func Save_scan_target_row(database_interface *sql.DB, scan_target_row Scan_target_row) error {
+
+	save_row_query := fmt.Sprintf(
+		"INSERT OR REPLACE INTO scan_targets(scan_id, target, status, seed, certificate_vhosts, combination, position, throttled) VALUES (%s, %s, %s, %d, %s, %d, %d, %s);",
+		QuoteString(scan_target_row.Scan_id),
+		QuoteString(scan_target_row.Target),
+		QuoteString(scan_target_row.Status),
+		scan_target_row.Seed,
+		QuoteString(scan_target_row.Certificate_vhosts),
+		scan_target_row.Combination,
+		scan_target_row.Position,
+		QuoteString(scan_target_row.Throttled),
+	)
+	_, db_row_err := database_interface.Exec(save_row_query)
+	if db_row_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while saving row using query: %s || Error: %s", save_row_query, db_row_err.Error()))
+	}
+	return nil
+}
+
+// Get_scan_target_rows returns the checkpoints of every target of a scan, keyed by target.
+func Get_scan_target_rows(database_interface *sql.DB, scan_id string) (map[string]Scan_target_row, error) {
+
+	scan_target_rows, query_err := database_interface.Query(fmt.Sprintf("SELECT scan_id, target, status, seed, certificate_vhosts, combination, position, throttled FROM scan_targets WHERE scan_id = %s;", QuoteString(scan_id)))
+	if query_err != nil {
+		return nil, errors.New("An error occurred while reading the targets of scan: " + scan_id + " || Error: " + query_err.Error())
+	}
+	defer scan_target_rows.Close()
+
+	scan_targets := map[string]Scan_target_row{}
+	for scan_target_rows.Next() {
+		scan_target_row := Scan_target_row{}
+		scan_err := scan_target_rows.Scan(&scan_target_row.Scan_id, &scan_target_row.Target, &scan_target_row.Status, &scan_target_row.Seed, &scan_target_row.Certificate_vhosts, &scan_target_row.Combination, &scan_target_row.Position, &scan_target_row.Throttled)
+		if scan_err != nil {
+			return nil, errors.New("An error occurred while reading the targets of scan: " + scan_id + " || Error: " + scan_err.Error())
+		}
+		scan_targets[scan_target_row.Target] = scan_target_row
+	}
+	if rows_err := scan_target_rows.Err(); rows_err != nil {
+		return nil, errors.New("An error occurred while reading the targets of scan: " + scan_id + " || Error: " + rows_err.Error())
+	}
+	return scan_targets, nil
+}
+
+func AddScanHitRowToTable(database_interface *sql.DB, scan_hit_row Scan_hit_row) error {
+
+	add_row_query := fmt.Sprintf(
+		"INSERT INTO scan_hits(scan_id, target, combination, hit) VALUES (%s, %s, %d, %s);",
+		QuoteString(scan_hit_row.Scan_id),
+		QuoteString(scan_hit_row.Target),
+		scan_hit_row.Combination,
+		QuoteString(scan_hit_row.Hit),
+	)
+	_, db_row_err := database_interface.Exec(add_row_query)
+	if db_row_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while adding row using query: %s || Error: %s", add_row_query, db_row_err.Error()))
+	}
+	return nil
+}
+
+// Get_scan_hit_rows returns the hits of a target that were checkpointed in scan_id, in the order they were found.
+func Get_scan_hit_rows(database_interface *sql.DB, scan_id string, target string) ([]Scan_hit_row, error) {
+
+	scan_hit_rows, query_err := database_interface.Query(fmt.Sprintf("SELECT scan_id, target, combination, hit FROM scan_hits WHERE scan_id = %s AND target = %s ORDER BY rowid;", QuoteString(scan_id), QuoteString(target)))
+	if query_err != nil {
+		return nil, errors.New("An error occurred while reading the hits of target: " + target + " || Error: " + query_err.Error())
+	}
+	defer scan_hit_rows.Close()
+
+	var scan_hits []Scan_hit_row
+	for scan_hit_rows.Next() {
+		scan_hit_row := Scan_hit_row{}
+		scan_err := scan_hit_rows.Scan(&scan_hit_row.Scan_id, &scan_hit_row.Target, &scan_hit_row.Combination, &scan_hit_row.Hit)
+		if scan_err != nil {
+			return nil, errors.New("An error occurred while reading the hits of target: " + target + " || Error: " + scan_err.Error())
+		}
+		scan_hits = append(scan_hits, scan_hit_row)
+	}
+	if rows_err := scan_hit_rows.Err(); rows_err != nil {
+		return nil, errors.New("An error occurred while reading the hits of target: " + target + " || Error: " + rows_err.Error())
+	}
+	return scan_hits, nil
+}
+
+// Delete_scan_hit_rows deletes the checkpointed hits of a target, only those of combination when it is >= 0.
+func Delete_scan_hit_rows(database_interface *sql.DB, scan_id string, target string, combination int) error {
+
+	delete_rows_query := fmt.Sprintf("DELETE FROM scan_hits WHERE scan_id = %s AND target = %s", QuoteString(scan_id), QuoteString(target))
+	if combination >= 0 {
+		delete_rows_query += fmt.Sprintf(" AND combination = %d", combination)
+	}
+	_, db_delete_err := database_interface.Exec(delete_rows_query + ";")
+	if db_delete_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while deleting rows using query: %s || Error: %s", delete_rows_query, db_delete_err.Error()))
+	}
+	return nil
+}
+
+// Delete_enumerated_vhost_rows deletes the rows a scan stored for a target, so storing the target's results again
+// (e.g. once a resumed scan finishes it) does not duplicate them.
+func Delete_enumerated_vhost_rows(database_interface *sql.DB, scan_id string, target string) error {
+
+	delete_rows_query := fmt.Sprintf("DELETE FROM enumerated_vhosts WHERE scan_id = %s AND target = %s;", QuoteString(scan_id), QuoteString(target))
+	_, db_delete_err := database_interface.Exec(delete_rows_query)
+	if db_delete_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while deleting rows using query: %s || Error: %s", delete_rows_query, db_delete_err.Error()))
+	}
+	return nil
+}
@@ -5,15 +5,32 @@ import (
 	"errors"
 	"fmt"
 	_ "modernc.org/sqlite"
+	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type Table_row struct {
+	Scan_id                     string // Scan the row was found in, rows of a target are replaced when a resumed scan finishes it
 	Target                      string
 	Vhost                       string
 	Baseline_response_body_md5  string
 	Spoofed_response_body_md5   string
 	Spoofed_request_status_code int
+	Similarity_distance         float64
+	Response_fingerprint        string  // JSON encoded request_utils.ResponseFingerprint
+	Differing_fields            string  // Comma separated fields on which the response differed from the baseline
+	Redirect_chain              string  // Redirect hops that were followed, joined with " -> "
+	Confirmations               int     // Number of confirmation rounds, 0 when the hit was not confirmed
+	Confidence                  float64 // Fraction of confirmation rounds the hit passed
+	Wildcard_suffix             string  // Wildcard suffix whose baseline the vhost was compared against, empty for the generic baseline
+	Finding_type                string  // "vhost" or "wildcard"
+	Probe_mode                  string  // Where the candidate was placed when probing (host, sni, both, mismatch)
+	Source                      string  // Where the candidate came from (wordlist, certificate, ...)
+	Injection_vector            string  // How the candidate was injected into the request (host, x-forwarded-host, ...)
+	Body_length                 int64   // Length of the decoded response body
+	Raw_body_length             int64   // Length of the response body as received (before Content-Encoding was undone)
+	Target_expression           string  // Targets list line the target was expanded from (CIDR block, IP range, port list, ...)
 }
 
 func QuoteString(s string) string {
@@ -21,12 +38,76 @@ func QuoteString(s string) string {
 	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
 }
 
+// ----| Databases whose enumerated_vhosts and checkpoint tables were created and upgraded, by absolute path
+var migrated_databases = map[string]bool{}
+var migrated_databases_mutex sync.Mutex
+
+// Open_database_interface opens a database. The enumerated_vhosts and checkpoint tables are created and upgraded the
+// first time a database is opened, not for every row that is written to them.
 func Open_database_interface(database_directory string) (*sql.DB, error) {
 	database_interface, database_interfaceError := sql.Open("sqlite", database_directory)
-	return database_interface, database_interfaceError
+	if database_interfaceError != nil {
+		return nil, database_interfaceError
+	}
+
+	database_path, abs_err := filepath.Abs(database_directory)
+	if abs_err != nil {
+		database_interface.Close()
+		return nil, errors.New("An error occurred while resolving the path of database: " + database_directory + " || Error: " + abs_err.Error())
+	}
+	migrated_databases_mutex.Lock()
+	defer migrated_databases_mutex.Unlock()
+	if !migrated_databases[database_path] {
+		migrate_err := Ensure_enumerated_vhosts_table(database_interface)
+		if migrate_err == nil {
+			migrate_err = ensure_checkpoint_tables(database_interface)
+		}
+		if migrate_err != nil {
+			database_interface.Close()
+			return nil, migrate_err
+		}
+		migrated_databases[database_path] = true
+	}
+	return database_interface, nil
 }
 
-func AddRowToTable(database_interface *sql.DB, table_name string, table_row Table_row) error {
+// Add_column_if_missing adds a column to an existing table so databases created by older versions keep working.
+func Add_column_if_missing(database_interface *sql.DB, table_name string, column_name string, column_definition string) error {
+
+	// ----| Check whether column already exists
+	table_info_rows, table_info_err := database_interface.Query(fmt.Sprintf("PRAGMA table_info(%s);", table_name))
+	if table_info_err != nil {
+		return errors.New("An error occurred while reading the columns of table: " + table_name + " || Error: " + table_info_err.Error())
+	}
+	defer table_info_rows.Close()
+
+	for table_info_rows.Next() {
+		var column_id int
+		var existing_column_name, column_type string
+		var column_not_null, column_is_primary_key int
+		var column_default_value sql.NullString
+		scan_err := table_info_rows.Scan(&column_id, &existing_column_name, &column_type, &column_not_null, &column_default_value, &column_is_primary_key)
+		if scan_err != nil {
+			return errors.New("An error occurred while reading the columns of table: " + table_name + " || Error: " + scan_err.Error())
+		}
+		if existing_column_name == column_name {
+			return nil
+		}
+	}
+	if rows_err := table_info_rows.Err(); rows_err != nil {
+		return errors.New("An error occurred while reading the columns of table: " + table_name + " || Error: " + rows_err.Error())
+	}
+
+	// ----| Add missing column
+	_, alter_table_err := database_interface.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", table_name, column_name, column_definition))
+	if alter_table_err != nil {
+		return errors.New("An error occurred while adding column: " + column_name + " to table: " + table_name + " || Error: " + alter_table_err.Error())
+	}
+	return nil
+}
+
+// Ensure_enumerated_vhosts_table creates the enumerated_vhosts table and upgrades tables created by older versions.
+func Ensure_enumerated_vhosts_table(database_interface *sql.DB) error {
 
 	TableExistAndCreateQuery := fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS enumerated_vhosts(
@@ -34,23 +115,82 @@ func AddRowToTable(database_interface *sql.DB, table_name string, table_row Tabl
 		vhost TEXT NOT NULL,
 		baseline_response_body_md5 TEXT NOT NULL,
 		spoofed_response_body_md5 TEXT NOT NULL,
-		spoofed_request_status_code INT NOT NULL
+		spoofed_request_status_code INT NOT NULL,
+		similarity_distance REAL NOT NULL DEFAULT 0,
+		response_fingerprint TEXT NOT NULL DEFAULT '',
+		differing_fields TEXT NOT NULL DEFAULT '',
+		redirect_chain TEXT NOT NULL DEFAULT '',
+		confirmations INT NOT NULL DEFAULT 0,
+		confidence REAL NOT NULL DEFAULT 0,
+		wildcard_suffix TEXT NOT NULL DEFAULT '',
+		finding_type TEXT NOT NULL DEFAULT 'vhost',
+		probe_mode TEXT NOT NULL DEFAULT 'host',
+		source TEXT NOT NULL DEFAULT 'wordlist',
+		injection_vector TEXT NOT NULL DEFAULT 'host',
+		body_length INT NOT NULL DEFAULT 0,
+		raw_body_length INT NOT NULL DEFAULT 0,
+		scan_id TEXT NOT NULL DEFAULT '',
+		target_expression TEXT NOT NULL DEFAULT ''
 	);`)
 	_, db_table_err := database_interface.Exec(TableExistAndCreateQuery)
 	if db_table_err != nil {
 		return errors.New("An error occurred while creating the database table || Error: " + db_table_err.Error())
 	}
 
+	// ----| Upgrade tables created by older versions
+	added_columns := [][2]string{
+		{"similarity_distance", "REAL NOT NULL DEFAULT 0"},
+		{"response_fingerprint", "TEXT NOT NULL DEFAULT ''"},
+		{"differing_fields", "TEXT NOT NULL DEFAULT ''"},
+		{"redirect_chain", "TEXT NOT NULL DEFAULT ''"},
+		{"confirmations", "INT NOT NULL DEFAULT 0"},
+		{"confidence", "REAL NOT NULL DEFAULT 0"},
+		{"wildcard_suffix", "TEXT NOT NULL DEFAULT ''"},
+		{"finding_type", "TEXT NOT NULL DEFAULT 'vhost'"},
+		{"probe_mode", "TEXT NOT NULL DEFAULT 'host'"},
+		{"source", "TEXT NOT NULL DEFAULT 'wordlist'"},
+		{"injection_vector", "TEXT NOT NULL DEFAULT 'host'"},
+		{"body_length", "INT NOT NULL DEFAULT 0"},
+		{"raw_body_length", "INT NOT NULL DEFAULT 0"},
+		{"scan_id", "TEXT NOT NULL DEFAULT ''"},
+		{"target_expression", "TEXT NOT NULL DEFAULT ''"},
+	}
+	for _, added_column := range added_columns {
+		add_column_err := Add_column_if_missing(database_interface, "enumerated_vhosts", added_column[0], added_column[1])
+		if add_column_err != nil {
+			return add_column_err
+		}
+	}
+	return nil
+}
+
+func AddRowToTable(database_interface *sql.DB, table_name string, table_row Table_row) error {
+
 	add_row_query := fmt.Sprintf(
 		"INSERT INTO %s("+
-			"target, vhost, baseline_response_body_md5, spoofed_response_body_md5, spoofed_request_status_code"+
-			") VALUES (%s, %s, %s, %s, %d);",
+			"target, vhost, baseline_response_body_md5, spoofed_response_body_md5, spoofed_request_status_code, similarity_distance, response_fingerprint, differing_fields, redirect_chain, confirmations, confidence, wildcard_suffix, finding_type, probe_mode, source, injection_vector, body_length, raw_body_length, scan_id, target_expression"+
+			") VALUES (%s, %s, %s, %s, %d, %f, %s, %s, %s, %d, %f, %s, %s, %s, %s, %s, %d, %d, %s, %s);",
 		table_name,
 		QuoteString(table_row.Target),
 		QuoteString(table_row.Vhost),
 		QuoteString(table_row.Baseline_response_body_md5),
 		QuoteString(table_row.Spoofed_response_body_md5),
 		table_row.Spoofed_request_status_code,
+		table_row.Similarity_distance,
+		QuoteString(table_row.Response_fingerprint),
+		QuoteString(table_row.Differing_fields),
+		QuoteString(table_row.Redirect_chain),
+		table_row.Confirmations,
+		table_row.Confidence,
+		QuoteString(table_row.Wildcard_suffix),
+		QuoteString(table_row.Finding_type),
+		QuoteString(table_row.Probe_mode),
+		QuoteString(table_row.Source),
+		QuoteString(table_row.Injection_vector),
+		table_row.Body_length,
+		table_row.Raw_body_length,
+		QuoteString(table_row.Scan_id),
+		QuoteString(table_row.Target_expression),
 	)
 	//fmt.Println(add_row_query) // DEBUG
 	_, db_row_err := database_interface.Exec(add_row_query)
@@ -67,3 +207,86 @@ func Close_database_interface(database_interface *sql.DB) error {
 	}
 	return nil
 }
+
+type Certificate_row struct {
+	Target             string
+	Subject_cn         string
+	Dns_names          string // Comma separated subjectAltName DNS entries
+	Issuer             string
+	Not_before         string // RFC 3339
+	Not_after          string // RFC 3339
+	Sha256_fingerprint string
+}
+
+func AddCertificateRowToTable(database_interface *sql.DB, certificate_row Certificate_row) error {
+
+	TableExistAndCreateQuery := `
+	CREATE TABLE IF NOT EXISTS certificates(
+	    target TEXT NOT NULL,
+		subject_cn TEXT NOT NULL,
+		dns_names TEXT NOT NULL,
+		issuer TEXT NOT NULL,
+		not_before TEXT NOT NULL,
+		not_after TEXT NOT NULL,
+		sha256_fingerprint TEXT NOT NULL
+	);`
+	_, db_table_err := database_interface.Exec(TableExistAndCreateQuery)
+	if db_table_err != nil {
+		return errors.New("An error occurred while creating the certificates table || Error: " + db_table_err.Error())
+	}
+
+	add_row_query := fmt.Sprintf(
+		"INSERT INTO certificates("+
+			"target, subject_cn, dns_names, issuer, not_before, not_after, sha256_fingerprint"+
+			") VALUES (%s, %s, %s, %s, %s, %s, %s);",
+		QuoteString(certificate_row.Target),
+		QuoteString(certificate_row.Subject_cn),
+		QuoteString(certificate_row.Dns_names),
+		QuoteString(certificate_row.Issuer),
+		QuoteString(certificate_row.Not_before),
+		QuoteString(certificate_row.Not_after),
+		QuoteString(certificate_row.Sha256_fingerprint),
+	)
+	_, db_row_err := database_interface.Exec(add_row_query)
+	if db_row_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while adding row using query: %s || Error: %s", add_row_query, db_row_err.Error()))
+	}
+	return nil
+}
+
+type Harvested_vhost_row struct {
+	Target             string
+	Vhost              string
+	Source             string // Where the vhost was harvested from, e.g. "certificate"
+	Sha256_fingerprint string // Fingerprint of the certificate the vhost was harvested from
+}
+
+func AddHarvestedVhostRowToTable(database_interface *sql.DB, harvested_vhost_row Harvested_vhost_row) error {
+
+	TableExistAndCreateQuery := `
+	CREATE TABLE IF NOT EXISTS harvested_vhosts(
+	    target TEXT NOT NULL,
+		vhost TEXT NOT NULL,
+		source TEXT NOT NULL,
+		sha256_fingerprint TEXT NOT NULL
+	);`
+	_, db_table_err := database_interface.Exec(TableExistAndCreateQuery)
+	if db_table_err != nil {
+		return errors.New("An error occurred while creating the harvested vhosts table || Error: " + db_table_err.Error())
+	}
+
+	add_row_query := fmt.Sprintf(
+		"INSERT INTO harvested_vhosts("+
+			"target, vhost, source, sha256_fingerprint"+
+			") VALUES (%s, %s, %s, %s);",
+		QuoteString(harvested_vhost_row.Target),
+		QuoteString(harvested_vhost_row.Vhost),
+		QuoteString(harvested_vhost_row.Source),
+		QuoteString(harvested_vhost_row.Sha256_fingerprint),
+	)
+	_, db_row_err := database_interface.Exec(add_row_query)
+	if db_row_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while adding row using query: %s || Error: %s", add_row_query, db_row_err.Error()))
+	}
+	return nil
+}
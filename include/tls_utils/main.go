@@ -0,0 +1,102 @@
+package tls_utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"vhost-scout/include/input_utils"
+)
+
+// Certificate_info is the metadata of the leaf certificate presented by a target.
+type Certificate_info struct {
+	Target             string
+	Subject_cn         string
+	Dns_names          []string // subjectAltName DNS entries
+	Issuer             string
+	Not_before         time.Time
+	Not_after          time.Time
+	Sha256_fingerprint string
+}
+
+// Harvest_certificate sends a HEAD request to an https target over http_client's transport (its timeouts and proxy
+// apply) and returns the leaf certificate of the connection. The certificate is never verified, self-signed and
+// expired certificates often list the most interesting internal names. The request is abandoned once ctx is done.
+func Harvest_certificate(ctx context.Context, target string, http_client *http.Client) (Certificate_info, error) {
+
+	target_url, url_parse_err := url.Parse(target)
+	if url_parse_err != nil {
+		return Certificate_info{}, errors.New("An error occurred while parsing target: " + target + " || Error: " + url_parse_err.Error())
+	}
+	if target_url.Scheme != "https" {
+		return Certificate_info{}, errors.New("Cannot harvest a certificate from non https target: " + target)
+	}
+
+	// ----| Copy client without certificate verification, redirects or keep-alive
+	base_transport, is_http_transport := http_client.Transport.(*http.Transport)
+	if !is_http_transport {
+		return Certificate_info{}, errors.New("Cannot harvest a certificate with a client that does not use an *http.Transport")
+	}
+	harvest_transport := base_transport.Clone()
+	if harvest_transport.TLSClientConfig == nil {
+		harvest_transport.TLSClientConfig = &tls.Config{}
+	}
+	harvest_transport.TLSClientConfig.InsecureSkipVerify = true // We want the certificate, not a verified connection
+	harvest_transport.DisableKeepAlives = true
+	defer harvest_transport.CloseIdleConnections()
+	harvest_client := *http_client
+	harvest_client.Transport = harvest_transport
+	harvest_client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	// ----| Connect and grab leaf certificate
+	harvest_request, new_request_err := http.NewRequestWithContext(ctx, http.MethodHead, target_url.String(), nil)
+	if new_request_err != nil {
+		return Certificate_info{}, errors.New("An error occurred while building a request to: " + target + " || Error: " + new_request_err.Error())
+	}
+	harvest_response, harvest_err := harvest_client.Do(harvest_request)
+	if harvest_err != nil {
+		return Certificate_info{}, errors.New("An error occurred during the TLS handshake with: " + target + " || Error: " + harvest_err.Error())
+	}
+	harvest_response.Body.Close()
+
+	if harvest_response.TLS == nil || len(harvest_response.TLS.PeerCertificates) == 0 {
+		return Certificate_info{}, errors.New("No certificate was presented by: " + target)
+	}
+	peer_certificates := harvest_response.TLS.PeerCertificates
+	leaf_certificate := peer_certificates[0]
+	leaf_certificate_sha256 := sha256.Sum256(leaf_certificate.Raw)
+
+	return Certificate_info{
+		Target:             target,
+		Subject_cn:         leaf_certificate.Subject.CommonName,
+		Dns_names:          leaf_certificate.DNSNames,
+		Issuer:             leaf_certificate.Issuer.String(),
+		Not_before:         leaf_certificate.NotBefore,
+		Not_after:          leaf_certificate.NotAfter,
+		Sha256_fingerprint: hex.EncodeToString(leaf_certificate_sha256[:]),
+	}, nil
+}
+
+// Candidate_names returns the deduplicated hostnames listed in the certificate's CN and SAN entries. Wildcard entries
+// (*.apps.example.com) are returned as their suffix (apps.example.com), names are normalized the way vhosts lists are
+// and entries that are not hostnames are skipped.
+func (certificate_info Certificate_info) Candidate_names() []string {
+
+	var candidate_names []string
+	seen_names := map[string]bool{}
+	for _, name := range append([]string{certificate_info.Subject_cn}, certificate_info.Dns_names...) {
+		name, normalize_err := input_utils.Normalize_hostname(strings.TrimPrefix(strings.TrimSpace(name), "*."))
+		if normalize_err != nil || net.ParseIP(name) != nil || seen_names[name] {
+			continue
+		}
+		seen_names[name] = true
+		candidate_names = append(candidate_names, name)
+	}
+	return candidate_names
+}
@@ -1,22 +1,33 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/fatih/color"
-	"math/rand"
-	"net/http"
+	"io"
+	"net/url"
 	"os"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 	"vhost-scout/include/banner_utils"
+	"vhost-scout/include/baseline_utils"
 	"vhost-scout/include/file_utils"
 	"vhost-scout/include/input_utils"
+	"vhost-scout/include/match_utils"
+	"vhost-scout/include/normalize_utils"
 	"vhost-scout/include/random_utils"
 	"vhost-scout/include/request_utils"
+	"vhost-scout/include/schedule_utils"
 	"vhost-scout/include/sqlite_utils"
 )
 
@@ -25,166 +36,933 @@ type t_target_that_encountered_error struct {
 	error  error
 }
 
+var err_interrupted = errors.New("Interrupted before the target was finished, the vhosts found so far were kept")
+
+// err_scheme_not_answering is returned for a target whose implied scheme nothing answers on, it is not a failure.
+var err_scheme_not_answering = errors.New("Nothing answers the scheme the target was given without")
+
 type t_vhost struct {
 	target                      string
 	vhost                       string
 	baseline_response_body_md5  string
 	spoofed_response_body_md5   string
 	spoofed_request_status_code int
+	similarity_distance         float64
+	fingerprint                 request_utils.ResponseFingerprint
+	differing_fields            []string
+	confirmations               int     // Number of confirmation rounds the vhost was re-probed in
+	confidence                  float64 // Fraction of confirmation rounds in which the vhost still differed from the baseline
+	wildcard_suffix             string  // Wildcard suffix whose baseline the vhost was compared against, empty for the generic baseline
+	finding_type                string
+	probe_mode                  string // Where the candidate was placed (Host header, SNI, ...) when the hit was found
+	source                      string // Where the candidate came from (wordlist, certificate)
+	injection_vector            string // How the candidate was injected into the request (Host header, X-Forwarded-Host, ...)
 }
 
-func process_target(target string, vhosts_list []string) ([]t_vhost, error) {
+// ----| Kinds of findings stored in the enumerated_vhosts table
+const (
+	finding_type_vhost    = "vhost"
+	finding_type_wildcard = "wildcard"
+)
 
-	// ----| Shuffle vhosts list to avoid basic defences
-	rand.Shuffle(len(vhosts_list), func(i, j int) {
-		vhosts_list[i], vhosts_list[j] = vhosts_list[j], vhosts_list[i]
-	})
+// t_baseline is a baseline model together with the kind of random Host header it was calibrated with, so that
+// later probes (e.g. confirmation rounds) can send comparable random Host headers.
+type t_baseline struct {
+	wildcard_suffix string // Empty for the generic random host baseline
+	model           baseline_utils.Baseline_model
+}
+
+// status_codes returns the status codes the baseline was calibrated with.
+func (baseline t_baseline) status_codes() map[int]bool {
+	status_codes := map[int]bool{}
+	for _, sample := range baseline.model.Samples {
+		status_codes[sample.Fingerprint.Status_code] = true
+	}
+	return status_codes
+}
+
+// drop_raw_responses drops the raw bodies and headers of the baseline's samples once the match and filter rules no
+// longer need them, the baseline is kept for the whole target.
+func (baseline t_baseline) drop_raw_responses() {
+	for i := range baseline.model.Samples {
+		baseline.model.Samples[i].Fingerprint = baseline.model.Samples[i].Fingerprint.Without_raw_response()
+	}
+}
+
+// random_host returns a random Host header of the kind the baseline was calibrated with.
+func (baseline t_baseline) random_host(shape int) string {
+	if baseline.wildcard_suffix == "" {
+		return random_utils.Gen_random_host(shape)
+	}
+	return random_utils.Gen_random_subdomain(baseline.wildcard_suffix)
+}
+
+// t_scan_options holds the command line configuration that is threaded through run and process_target.
+type t_scan_options struct {
+	targets_file_path_or_target_url string
+	schemes_list                    string
+	target_expression               string // Targets list line the target being scanned was expanded from, set per target by run
+	implied_scheme                  bool   // The target's scheme is one of several tried for a line without one, set per target by run
+	vhosts_lists_path               string
+	wordlist                        t_wordlist // Read by run from vhosts_lists_path
+	shuffle_window                  int
+	allow_insecure_requests         bool
+	baseline_samples                int
+	similarity_threshold            float64
+	normalization_rules_path        string
+	disable_normalization           bool
+	compare_fields_list             string
+	compare_fields                  []string // Parsed from compare_fields_list by run
+	redirect_mode                   string
+	probe_config                    request_utils.Probe_config // Built by run from the normalization and redirect options
+	match_rules_input               match_utils.Rule_set_input
+	filter_rules_input              match_utils.Rule_set_input
+	match_rules                     match_utils.Rules // Built by run from the match and filter inputs
+	confirmations                   int
+	min_confidence                  float64
+	wildcard_samples                int
+	wildcard_min_candidates         int
+	wildcard_max_suffixes           int
+	probe_modes_list                string
+	probe_modes                     []string // Parsed from probe_modes_list by run
+	sni_front                       string
+	disable_certificate_harvesting  bool
+	injection_vectors_list          string
+	injection_vectors               []string // Parsed from injection_vectors_list by run
+	header_profiles_path            string
+	header_profile_scope            string
+	header_profiles                 []request_utils.Header_profile // Loaded from header_profiles_path by run
+	concurrency                     int
+	parallel_targets                int
+	global_concurrency              int
+	per_host_concurrency            int
+	rate                            float64
+	rate_per_host                   float64
+	burst                           int
+	jitter_distribution             string
+	jitter_min                      time.Duration
+	jitter_max                      time.Duration
+	throttle_status_codes_list      string
+	throttle_backoff                time.Duration
+	throttle_max_backoff            time.Duration
+	throttle_retries                int
+	connect_timeout                 time.Duration
+	tls_timeout                     time.Duration
+	total_timeout                   time.Duration
+	max_idle_conns                  int
+	max_idle_conns_per_host         int
+	idle_conn_timeout               time.Duration
+	retries                         int
+	retry_backoff                   time.Duration
+	max_consecutive_errors          int
+	error_budget                    *t_error_budget // Shared by the workers of the target being scanned, set per target by process_target
+	console                         io.Writer       // Where the output of the target being scanned goes, set per target by run
+	arguments                       []string        // Command line arguments, stored with the scan so it can be resumed
+	resume_scan_id                  string
+	checkpoint                      *t_checkpoint // Progress of the target being scanned, set per target by run
+}
+
+// calibrate_baseline sends several probes with differently shaped random Host headers to the target and builds
+// a model of how it responds to requests for vhosts that do not exist. When wildcard_suffix is not empty the
+// random Host headers are random labels under that suffix.
+func calibrate_baseline(ctx context.Context, target string, wildcard_suffix string, baseline_samples int, options t_scan_options) (t_baseline, error) {
+
+	// ----| Ensure at least one baseline probe is sent
+	if baseline_samples <= 0 {
+		baseline_samples = 1
+	}
+
+	baseline := t_baseline{wildcard_suffix: wildcard_suffix}
+	var samples []baseline_utils.Baseline_sample
+	for shape := range baseline_samples {
+		random_host := baseline.random_host(shape)
+		baseline_resp_fingerprint, baseline_req_err := send_probe(ctx, target, random_host, options)
+		if baseline_req_err != nil {
+			return t_baseline{}, errors.New("Error occurred while attempting to make baseline request to: " + target + " with Host header: " + random_host + "\n" + baseline_req_err.Error())
+		}
+
+		samples = append(samples, baseline_utils.Baseline_sample{
+			Vhost:       random_host,
+			Fingerprint: baseline_resp_fingerprint,
+		})
+	}
+
+	baseline_model, build_model_err := baseline_utils.Build_baseline_model(samples, options.similarity_threshold)
+	if build_model_err != nil {
+		return t_baseline{}, build_model_err
+	}
+	baseline.model = baseline_model
+	return baseline, nil
+}
+
+// print_enumerated_vhost prints a hit, colored by its status code.
+func print_enumerated_vhost(console io.Writer, vhost_information t_vhost) {
+
+	status_code := vhost_information.spoofed_request_status_code
+	details := fmt.Sprintf("(Status Code: %d, Differs on: %s)\n\n", status_code, strings.Join(vhost_information.differing_fields, ", "))
+
+	if vhost_information.fingerprint.Location != "" {
+		details = fmt.Sprintf("(Status Code: %d, Location: %s, Differs on: %s)\n\n", status_code, vhost_information.fingerprint.Location, strings.Join(vhost_information.differing_fields, ", "))
+	}
+	if vhost_information.probe_mode != "" && vhost_information.probe_mode != request_utils.Probe_mode_host {
+		details = "[" + vhost_information.probe_mode + "] " + details
+	}
+	if vhost_information.injection_vector != "" && vhost_information.injection_vector != request_utils.Injection_vector_host {
+		details = "[" + vhost_information.injection_vector + "] " + details
+	}
+
+	switch {
+	case strings.HasPrefix(strconv.Itoa(status_code), "2"):
+		fmt.Fprintf(console, "  > %s %s", vhost_information.vhost, color.GreenString(details))
+	case strings.HasPrefix(strconv.Itoa(status_code), "3"):
+		fmt.Fprintf(console, "  > %s %s", vhost_information.vhost, color.YellowString(details))
+	case strings.HasPrefix(strconv.Itoa(status_code), "4") || strings.HasPrefix(strconv.Itoa(status_code), "5"):
+		fmt.Fprintf(console, "  > %s %s", vhost_information.vhost, color.RedString(details))
+	default:
+		fmt.Fprintf(console, "  > %s %s", vhost_information.vhost, color.RedString(details))
+	}
 
-	// ----| Make initial request to target with random host header to establish baseline response to requests to non-existent vhosts
-	baseline_resp_md5_hash, _, baseline_req_err := request_utils.Send_request_with_spoofed_host_header(target, random_utils.Gen_random_string(rand.Intn(10))+".com") // Send baseline request with a spoofed Host header set to a random 1 to 10 letter string followed by .com
-	if baseline_req_err != nil {
-		return nil, errors.New("Error occurred while attempting to make baseline request to: " + target + " with Host header: " + target + "\n" + baseline_req_err.Error())
+	// ----| Print the redirects that were followed to reach the response
+	for _, redirect_hop := range vhost_information.fingerprint.Redirect_chain {
+		fmt.Fprintf(console, "      -> %s\n", redirect_hop)
+	}
+	if len(vhost_information.fingerprint.Redirect_chain) != 0 {
+		fmt.Fprintln(console)
 	}
+}
+
+func process_target(ctx context.Context, target string, certificate_vhosts []string, options t_scan_options) ([]t_vhost, error) {
 
+	// ----| Check the suffixes of the names harvested from the target's certificate for wildcards too
+	suffixes := options.wordlist.suffixes.clone()
+	for _, certificate_vhost := range certificate_vhosts {
+		suffixes.add(certificate_vhost)
+	}
+	wildcard_suffixes, too_rare_suffixes, over_cap_suffixes := suffixes.selected(options.wildcard_min_candidates, options.wildcard_max_suffixes)
+	if options.wildcard_samples > 0 && too_rare_suffixes+over_cap_suffixes+suffixes.untracked != 0 {
+		fmt.Fprintf(options.console, "  > Not checking suffixes for wildcards: %d shared by fewer than %d candidates, %d over --wildcard-max-suffixes, %d candidate suffix(es) not counted\n\n", too_rare_suffixes, options.wildcard_min_candidates, over_cap_suffixes, suffixes.untracked)
+	}
+
+	// ----| Failed candidates count against one budget across every probe mode and injection vector of the target
+	options.error_budget = new_error_budget(options.max_consecutive_errors)
+
+	// ----| Use one header profile for the baseline and every candidate of the target
+	if options.header_profile_scope == request_utils.Header_profile_scope_target {
+		header_profile := request_utils.Pick_header_profile(options.header_profiles)
+		options.probe_config.Header_profile = &header_profile
+		fmt.Fprintf(options.console, "  > Header profile: %s (User-Agent: %s)\n\n", header_profile.Name, header_profile.Headers.Get("User-Agent"))
+	}
+
+	// ----| Enumerate once per probe mode and injection vector, every combination gets its own baseline
 	var enumerated_vhosts []t_vhost
-	for _, vhost := range vhosts_list {
+	combination := -1
+	for _, probe_mode := range options.probe_modes {
 
-		// ----| Send request with spoofed Host header
-		spoofed_req_md5_hash, spoofed_request_interface, spoofed_req_err := request_utils.Send_request_with_spoofed_host_header(target, vhost)
-		if spoofed_req_err != nil {
-			return nil, errors.New("Error occurred while attempting to send spoofed request to: " + target + "with Host header: " + target + "\n" + spoofed_req_err.Error())
+		if request_utils.Sets_sni(probe_mode) && !strings.HasPrefix(strings.ToLower(target), "https://") {
+			fmt.Fprintf(options.console, "  > Skipping probe mode: %s (SNI is only sent to https targets)\n\n", probe_mode)
+			continue
 		}
 
-		if spoofed_req_md5_hash != baseline_resp_md5_hash {
+		// ----| The sni mode leaves the Host header alone so there is nothing to inject
+		injection_vectors := options.injection_vectors
+		if probe_mode == request_utils.Probe_mode_sni {
+			injection_vectors = []string{""}
+		}
+
+		for _, injection_vector := range injection_vectors {
+			combination++
+			if len(options.probe_modes) > 1 || len(options.injection_vectors) > 1 {
+				fmt.Fprintf(options.console, "  > Probe mode: %s, Injection vector: %s\n\n", probe_mode, injection_vector_label(injection_vector))
+			}
 
-			switch {
-			case strings.HasPrefix(strconv.Itoa(spoofed_request_interface.StatusCode), "2"):
-				fmt.Printf("  > %s %s", vhost, color.GreenString("(Status Code: %d)\n\n", spoofed_request_interface.StatusCode))
-			case strings.HasPrefix(strconv.Itoa(spoofed_request_interface.StatusCode), "3"):
-				fmt.Printf("  > %s %s", vhost, color.YellowString("(Status Code: %d)\n\n", spoofed_request_interface.StatusCode))
-			case strings.HasPrefix(strconv.Itoa(spoofed_request_interface.StatusCode), "4") || strings.HasPrefix(strconv.Itoa(spoofed_request_interface.StatusCode), "5"):
-				fmt.Printf("  > %s %s", vhost, color.RedString("(Status Code: %d)\n\n", spoofed_request_interface.StatusCode))
-			default:
-				fmt.Printf("  > %s %s", vhost, color.RedString("(Status Code: %d)\n\n", spoofed_request_interface.StatusCode))
+			// ----| Combinations finished before the scan was resumed are not probed again
+			if combination < options.checkpoint.row.Combination {
+				confirmed_vhosts := options.checkpoint.hits[combination]
+				fmt.Fprintf(options.console, "  > Already enumerated before the scan was resumed (%d vhost(s))\n\n", len(confirmed_vhosts))
+				enumerated_vhosts = append(enumerated_vhosts, confirmed_vhosts...)
+				continue
 			}
 
-			vhost_information := t_vhost{
-				target:                      target,
-				vhost:                       vhost,
-				baseline_response_body_md5:  baseline_resp_md5_hash,
-				spoofed_response_body_md5:   spoofed_req_md5_hash,
-				spoofed_request_status_code: spoofed_request_interface.StatusCode,
+			combination_options := options
+			combination_options.probe_config.Probe_mode = probe_mode
+			combination_options.probe_config.Injection_vector = injection_vector
+			enumerated_vhosts_in_combination, enumeration_err := enumerate_target(ctx, target, certificate_vhosts, wildcard_suffixes, combination_options)
+			if enumeration_err != nil {
+				return append(enumerated_vhosts, enumerated_vhosts_in_combination...), enumeration_err
+			}
+			options.checkpoint.complete_combination(enumerated_vhosts_in_combination)
+			enumerated_vhosts = append(enumerated_vhosts, enumerated_vhosts_in_combination...)
+		}
+	}
+	return enumerated_vhosts, nil
+}
+
+// scan_combinations returns the probe mode / injection vector combinations https targets are enumerated with, in order.
+// http targets skip the probe modes that set SNI.
+func scan_combinations(options t_scan_options) []string {
+	var combinations []string
+	for _, probe_mode := range options.probe_modes {
+		if probe_mode == request_utils.Probe_mode_sni {
+			combinations = append(combinations, probe_mode+"/"+injection_vector_label(""))
+			continue
+		}
+		for _, injection_vector := range options.injection_vectors {
+			combinations = append(combinations, probe_mode+"/"+injection_vector_label(injection_vector))
+		}
+	}
+	return combinations
+}
+
+// injection_vector_label returns the name printed and stored for an injection vector.
+func injection_vector_label(injection_vector string) string {
+	if injection_vector == "" {
+		return "none"
+	}
+	return injection_vector
+}
+
+// enumerate_target probes every candidate against target using the probe mode set in options.probe_config. Progress
+// within the probe mode / injection vector combination is recorded in options.checkpoint.
+func enumerate_target(ctx context.Context, target string, certificate_vhosts []string, wildcard_suffixes []string, options t_scan_options) ([]t_vhost, error) {
+
+	// ----| Calibrate baseline with several random host headers to model responses to requests to non-existent vhosts
+	generic_baseline, calibration_err := calibrate_baseline(ctx, target, "", options.baseline_samples, options)
+	if calibration_err != nil {
+		return options.checkpoint.combination_hits(), calibration_err
+	}
+	fmt.Fprintf(options.console, "  > Calibrated baseline from %d samples (%s)\n\n", len(generic_baseline.model.Samples), generic_baseline.model.Describe())
+	options.probe_config.Baseline_status_codes = generic_baseline.status_codes() // A 503 the baseline answers with is not throttling
+	generic_baseline.drop_raw_responses()
+
+	// ----| Continue from the hits and position checkpointed before the scan was resumed
+	enumerated_vhosts := options.checkpoint.combination_hits()
+	if options.checkpoint.row.Position != 0 {
+		fmt.Fprintf(options.console, "  > Skipping %d candidate(s) probed before the scan was resumed (%d hit(s))\n\n", options.checkpoint.row.Position, len(enumerated_vhosts))
+	}
+
+	// ----| Detect suffixes that answer for any label so their candidates are compared against the wildcard response
+	wildcard_baselines := map[string]t_baseline{}
+	if options.wildcard_samples > 0 && len(wildcard_suffixes) != 0 {
+		var wildcard_findings []t_vhost
+		wildcard_baselines, wildcard_findings = detect_wildcards(ctx, target, wildcard_suffixes, generic_baseline, options)
+		for _, wildcard_finding := range wildcard_findings {
+			if options.checkpoint.add_hit(wildcard_finding) {
+				enumerated_vhosts = append(enumerated_vhosts, wildcard_finding)
 			}
+		}
+	}
 
-			enumerated_vhosts = append(enumerated_vhosts, vhost_information)
+	// ----| Stream the candidates, the target's seed gives the same order every time
+	candidates, open_candidates_err := open_candidates(options.wordlist.path, certificate_vhosts, options.checkpoint.row.Seed, options.shuffle_window)
+	if open_candidates_err != nil {
+		return enumerated_vhosts, errors.New("An error occurred while reading vhosts from file: " + options.wordlist.path + " || Error: " + open_candidates_err.Error())
+	}
+	defer candidates.close()
+	candidates.skip(options.checkpoint.row.Position)
+
+	// ----| Probe candidates, candidates the target throttled are probed again once the backoff is over
+	next_candidate := candidates.next
+	for retry := 0; ; retry++ {
+		enumerated_vhosts_in_pass, throttled_candidates, probing_err := probe_candidates(ctx, target, next_candidate, generic_baseline, wildcard_baselines, options)
+		enumerated_vhosts = append(enumerated_vhosts, enumerated_vhosts_in_pass...)
+		if probing_err == nil && retry == 0 {
+			probing_err = candidates.err()
+		}
+		if probing_err != nil {
+			options.checkpoint.save()
+			return enumerated_vhosts, probing_err // Keep the hits found before the target was abandoned
 		}
+		if retry == 0 {
+			throttled_candidates = options.checkpoint.throttled_candidates() // Includes those throttled before the scan was resumed
+		}
+		if len(throttled_candidates) == 0 {
+			break
+		}
+
+		if retry >= options.throttle_retries {
+			var throttled_vhosts []string
+			for _, throttled_candidate := range throttled_candidates {
+				throttled_vhosts = append(throttled_vhosts, throttled_candidate.vhost)
+				options.checkpoint.mark_probed(throttled_candidate)
+			}
+			fmt.Fprintf(options.console, "  > Giving up on %d throttled candidate(s) after %d retries: %s\n\n", len(throttled_vhosts), options.throttle_retries, strings.Join(throttled_vhosts, ", "))
+			break
+		}
+		fmt.Fprintf(options.console, "  > Retrying %d throttled candidate(s)\n\n", len(throttled_candidates))
+		next_candidate = candidates_from_slice(throttled_candidates)
+	}
+	options.checkpoint.save()
+
+	// ----| Re-probe candidates to weed out transient differences
+	if options.confirmations > 0 && len(enumerated_vhosts) != 0 {
+		enumerated_vhosts = confirm_enumerated_vhosts(ctx, target, generic_baseline, wildcard_baselines, enumerated_vhosts, options)
+	}
 
-		sleep_time := rand.Intn(3) // n will be between 0 and 3
-		time.Sleep(time.Duration(sleep_time) * time.Second)
+	// ----| Hits whose confirmation was interrupted are confirmed again once the scan is resumed
+	if ctx.Err() != nil {
+		return enumerated_vhosts, ctx.Err()
 	}
 	return enumerated_vhosts, nil
 }
 
-func add_enumerated_vhosts_to_db(enumerated_vhosts []t_vhost) error {
+// probe_candidates probes the candidates handed out by next_candidate concurrently and returns the hits and the
+// candidates the target throttled. Candidates that keep failing are skipped until options.error_budget is spent, the
+// target is then abandoned and the hits found so far are returned with the error. Results are collected, printed and
+// checkpointed from the calling goroutine only.
+func probe_candidates(ctx context.Context, target string, next_candidate func() (t_candidate, bool), generic_baseline t_baseline, wildcard_baselines map[string]t_baseline, options t_scan_options) ([]t_vhost, []t_candidate, error) {
 
-	// ----| Ensure there are vhost to add to db
-	if len(enumerated_vhosts) == 0 {
-		fmt.Println("  > No vhosts were enumerated\n")
-		return nil
+	concurrency := max(options.concurrency, 1)
+	candidates_to_probe := make(chan t_candidate)
+	probe_results := make(chan t_probe_result)
+	stop_probing := make(chan struct{})
+
+	var workers sync.WaitGroup
+	for range concurrency {
+		workers.Go(func() {
+			for candidate := range candidates_to_probe {
+				baseline := select_baseline(candidate.vhost, generic_baseline, wildcard_baselines)
+				probe_result := probe_candidate(ctx, target, candidate, baseline, options)
+				probe_result.candidate = candidate
+				probe_results <- probe_result
+			}
+		})
+	}
+
+	go func() {
+		defer close(candidates_to_probe)
+		for candidate, has_candidate := next_candidate(); has_candidate; candidate, has_candidate = next_candidate() {
+			select {
+			case candidates_to_probe <- candidate:
+			case <-stop_probing:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		workers.Wait()
+		close(probe_results)
+	}()
+
+	var enumerated_vhosts []t_vhost
+	var throttled_candidates []t_candidate
+	var probing_err error
+	for probe_result := range probe_results {
+		if probe_result.throttled_err != nil {
+			log_backoff(probe_result.throttled_err, options)
+			options.checkpoint.mark_throttled(probe_result.candidate)
+			throttled_candidates = append(throttled_candidates, probe_result.candidate)
+			continue
+		}
+		if probe_result.err != nil {
+			if probing_err != nil || ctx.Err() != nil {
+				continue
+			}
+			if options.error_budget.record_error() {
+				probing_err = errors.New("Abandoning target after " + strconv.Itoa(options.max_consecutive_errors) + " consecutive failed candidates || Last error: " + probe_result.err.Error())
+				close(stop_probing) // Stop handing out candidates, in-flight probes drain through probe_results
+				continue
+			}
+			fmt.Fprintf(options.console, "  > Skipping: %s %s", probe_result.candidate.vhost, color.RedString("(%s error) || Error: %s\n\n", error_class(probe_result.err), root_cause(probe_result.err)))
+			options.checkpoint.mark_probed(probe_result.candidate)
+			continue
+		}
+		options.error_budget.record_success()
+		if probe_result.is_hit && options.checkpoint.add_hit(probe_result.vhost_information) { // Hits probed again after a resume are already held
+			print_enumerated_vhost(options.console, probe_result.vhost_information)
+			enumerated_vhosts = append(enumerated_vhosts, probe_result.vhost_information)
+		}
+		options.checkpoint.mark_probed(probe_result.candidate)
+	}
+	if probing_err == nil {
+		probing_err = ctx.Err() // Candidates that were never handed out are not probed when the scan is interrupted
+	}
+	return enumerated_vhosts, throttled_candidates, probing_err
+}
+
+// t_probe_result is what a worker reports back for a single candidate.
+type t_probe_result struct {
+	candidate         t_candidate
+	vhost_information t_vhost
+	is_hit            bool
+	throttled_err     *request_utils.Throttled_error // Set when the target throttled the probe, the candidate has to be probed again
+	err               error
+}
+
+// probe_candidate sends a single candidate to target and compares the response against baseline.
+func probe_candidate(ctx context.Context, target string, candidate t_candidate, baseline t_baseline, options t_scan_options) t_probe_result {
+
+	// ----| Send request with spoofed Host header
+	spoofed_req_fingerprint, spoofed_req_err := send_with_retries(ctx, target, candidate.vhost, options)
+	if throttled_err := as_throttled_error(spoofed_req_err); throttled_err != nil {
+		return t_probe_result{throttled_err: throttled_err}
+	}
+	if spoofed_req_err != nil {
+		return t_probe_result{err: spoofed_req_err}
+	}
+
+	if !is_hit(baseline.model, spoofed_req_fingerprint, options) {
+		return t_probe_result{}
+	}
+
+	differing_fields, similarity_distance := baseline.model.Is_outside_model(spoofed_req_fingerprint, options.compare_fields)
+	return t_probe_result{
+		is_hit: true,
+		vhost_information: t_vhost{
+			target:                      target,
+			vhost:                       candidate.vhost,
+			baseline_response_body_md5:  baseline.model.Representative_md5(),
+			spoofed_response_body_md5:   spoofed_req_fingerprint.Body_md5,
+			spoofed_request_status_code: spoofed_req_fingerprint.Status_code,
+			similarity_distance:         similarity_distance,
+			fingerprint:                 spoofed_req_fingerprint.Without_raw_response(),
+			differing_fields:            differing_fields,
+			wildcard_suffix:             baseline.wildcard_suffix,
+			finding_type:                finding_type_vhost,
+			probe_mode:                  options.probe_config.Probe_mode,
+			source:                      candidate.source,
+			injection_vector:            injection_vector_label(options.probe_config.Injection_vector),
+		},
+	}
+}
+
+// is_hit reports whether a response falls outside the baseline model and passes the match and filter rules.
+func is_hit(baseline_model baseline_utils.Baseline_model, fingerprint request_utils.ResponseFingerprint, options t_scan_options) bool {
+	differing_fields, _ := baseline_model.Is_outside_model(fingerprint, options.compare_fields)
+	return len(differing_fields) != 0 && options.match_rules.Keep(fingerprint)
+}
+
+// confirm_enumerated_vhosts re-probes every candidate options.confirmations times, sending a fresh baseline probe before
+// each candidate probe. A round only counts as passed when the baseline probe still fits the baseline model (the target
+// is not glitching) and the candidate still falls outside the model with that baseline probe added to it, on any compare
+// field. Candidates whose confidence (passed rounds / rounds) is below options.min_confidence are dropped.
+func confirm_enumerated_vhosts(ctx context.Context, target string, generic_baseline t_baseline, wildcard_baselines map[string]t_baseline, candidates []t_vhost, options t_scan_options) []t_vhost {
+
+	fmt.Fprintf(options.console, "  > Confirming %d candidate(s) with %d round(s) each\n\n", len(candidates), options.confirmations)
+
+	var confirmed_vhosts []t_vhost
+	for i, candidate := range candidates {
+
+		// ----| Wildcards were already confirmed by the consistency of their samples
+		if candidate.finding_type == finding_type_wildcard {
+			confirmed_vhosts = append(confirmed_vhosts, candidate)
+			continue
+		}
+		baseline := select_baseline(candidate.vhost, generic_baseline, wildcard_baselines)
+
+		passed_rounds := 0
+		for round := range options.confirmations {
+
+			// ----| Re-probe baseline
+			random_host := baseline.random_host(round)
+			baseline_resp_fingerprint, baseline_req_err := send_probe(ctx, target, random_host, options)
+			if baseline_req_err != nil || is_hit(baseline.model, baseline_resp_fingerprint, options) {
+				continue
+			}
+
+			// ----| Re-probe candidate
+			candidate_resp_fingerprint, candidate_req_err := send_probe(ctx, target, candidate.vhost, options)
+			if candidate_req_err != nil {
+				continue
+			}
+
+			// ----| The candidate has to stand out from the model widened by the fresh baseline probe, on any field
+			round_samples := append(slices.Clone(baseline.model.Samples), baseline_utils.Baseline_sample{Vhost: random_host, Fingerprint: baseline_resp_fingerprint})
+			round_model, build_model_err := baseline_utils.Build_baseline_model(round_samples, options.similarity_threshold)
+			if build_model_err == nil && is_hit(round_model, candidate_resp_fingerprint, options) {
+				passed_rounds++
+			}
+		}
+
+		// ----| Keep the candidates that could not be confirmed before the scan was interrupted, unconfirmed
+		if ctx.Err() != nil {
+			fmt.Fprintf(options.console, "  > Interrupted, keeping %d unconfirmed candidate(s)\n\n", len(candidates)-i)
+			confirmed_vhosts = append(confirmed_vhosts, candidates[i:]...)
+			break
+		}
+
+		candidate.confirmations = options.confirmations
+		candidate.confidence = float64(passed_rounds) / float64(options.confirmations)
+		if candidate.confidence >= options.min_confidence {
+			fmt.Fprintf(options.console, "  > Confirmed: %s %s", candidate.vhost, color.GreenString("(Confidence: %.0f%%, %d/%d rounds)\n\n", candidate.confidence*100, passed_rounds, options.confirmations))
+			confirmed_vhosts = append(confirmed_vhosts, candidate)
+		} else {
+			fmt.Fprintf(options.console, "  > Dropped: %s %s", candidate.vhost, color.RedString("(Confidence: %.0f%%, %d/%d rounds)\n\n", candidate.confidence*100, passed_rounds, options.confirmations))
+		}
+	}
+	return confirmed_vhosts
+}
+
+// database_mutex serializes database writes of targets that are scanned in parallel.
+var database_mutex sync.Mutex
+
+// vhost_to_table_row builds the enumerated_vhosts row of a hit.
+func vhost_to_table_row(vhost_information t_vhost) (sqlite_utils.Table_row, error) {
+
+	// ----| Serialize fingerprint so the reason a vhost was flagged can be inspected later
+	response_fingerprint_json, json_marshal_err := json.Marshal(vhost_information.fingerprint)
+	if json_marshal_err != nil {
+		return sqlite_utils.Table_row{}, errors.New("An error occurred while serializing the response fingerprint of vhost: " + vhost_information.vhost + " || Error: " + json_marshal_err.Error())
 	}
 
-	// ----| Open database interface
-	database_interface, open_db_interface_err := sqlite_utils.Open_database_interface("db.sqlite")
-	if open_db_interface_err != nil {
-		return errors.New("An error occurred while initializing the database interface || Error: " + open_db_interface_err.Error())
+	return sqlite_utils.Table_row{
+		Target:                      vhost_information.target,
+		Vhost:                       vhost_information.vhost,
+		Baseline_response_body_md5:  vhost_information.baseline_response_body_md5,
+		Spoofed_response_body_md5:   vhost_information.spoofed_response_body_md5,
+		Spoofed_request_status_code: vhost_information.spoofed_request_status_code,
+		Similarity_distance:         vhost_information.similarity_distance,
+		Response_fingerprint:        string(response_fingerprint_json),
+		Differing_fields:            strings.Join(vhost_information.differing_fields, ","),
+		Redirect_chain:              strings.Join(vhost_information.fingerprint.Redirect_chain, " -> "),
+		Confirmations:               vhost_information.confirmations,
+		Confidence:                  vhost_information.confidence,
+		Wildcard_suffix:             vhost_information.wildcard_suffix,
+		Finding_type:                vhost_information.finding_type,
+		Probe_mode:                  vhost_information.probe_mode,
+		Source:                      vhost_information.source,
+		Injection_vector:            vhost_information.injection_vector,
+		Body_length:                 vhost_information.fingerprint.Content_length,
+		Raw_body_length:             vhost_information.fingerprint.Raw_length,
+	}, nil
+}
+
+// add_enumerated_vhosts_to_db replaces the rows scan_id stored for target with enumerated_vhosts, so the partial
+// results of an interrupted target are not duplicated once a resumed scan finishes it.
+func add_enumerated_vhosts_to_db(scan_id string, target string, target_expression string, enumerated_vhosts []t_vhost) error {
+	return with_database(func(database_interface *sql.DB) error {
+
+		delete_rows_err := sqlite_utils.Delete_enumerated_vhost_rows(database_interface, scan_id, target)
+		if delete_rows_err != nil {
+			return delete_rows_err
+		}
+
+		for _, vhost_information := range enumerated_vhosts {
+
+			// ----| Build row
+			db_row, build_row_err := vhost_to_table_row(vhost_information)
+			if build_row_err != nil {
+				return build_row_err
+			}
+			db_row.Scan_id = scan_id
+			db_row.Target_expression = target_expression
+
+			// ----| Insert row into table
+			add_row_to_table_err := sqlite_utils.AddRowToTable(database_interface, "enumerated_vhosts", db_row)
+			if add_row_to_table_err != nil {
+				return errors.New("An error occurred while adding row to enumerated vhosts db table || Error: " + add_row_to_table_err.Error())
+			}
+		}
+		return nil
+	})
+}
+
+// scan_target harvests, enumerates and stores the vhosts of a single target, writing its output to options.console.
+func scan_target(ctx context.Context, target string, options t_scan_options) error {
+
+	fmt.Fprintf(options.console, "\n\n> Starting VHost Enumeration On: %s\n\n", target)
+
+	// ----| Drop the rate limiter's state of the host once no target on it is left
+	if target_url, url_parsing_err := url.Parse(target); url_parsing_err == nil {
+		options.probe_config.Rate_limiter.Track_host(target_url.Hostname())
+		defer options.probe_config.Rate_limiter.Forget_host(target_url.Hostname())
 	}
 
-	for _, vhost_information := range enumerated_vhosts {
+	// ----| Harvest extra candidates from the target's certificate, a resumed target reuses the names harvested before
+	var certificate_vhosts []string
+	if options.checkpoint.is_resumed {
+		certificate_vhosts = options.checkpoint.certificate_vhosts()
+		fmt.Fprintf(options.console, "  > Resuming target from candidate %d of combination %d\n\n", options.checkpoint.row.Position, options.checkpoint.row.Combination+1)
+	} else {
 
-		// ----| Build row
-		db_row := sqlite_utils.Table_row{
-			Target:                      vhost_information.target,
-			Vhost:                       vhost_information.vhost,
-			Baseline_response_body_md5:  vhost_information.baseline_response_body_md5,
-			Spoofed_response_body_md5:   vhost_information.spoofed_response_body_md5,
-			Spoofed_request_status_code: vhost_information.spoofed_request_status_code,
+		// ----| A target given without scheme is scanned over every scheme, the ones nothing answers on are skipped
+		if options.implied_scheme {
+			if scheme_err := check_scheme_answers(ctx, target, options); scheme_err != nil {
+				fmt.Fprintf(options.console, "  > Skipping target, nothing answers %s on its port || Error: %s\n\n", strings.Split(target, "://")[0], root_cause(scheme_err))
+				options.checkpoint.finish()
+				return err_scheme_not_answering
+			}
 		}
+		if options.disable_certificate_harvesting == false && ctx.Err() == nil && strings.HasPrefix(strings.ToLower(target), "https://") {
+			harvested_vhosts, harvest_err := harvest_certificate_vhosts(ctx, target, options.probe_config, options.console)
+			if harvest_err != nil {
+				fmt.Fprintf(options.console, "  > Could not harvest certificate names from: %s || Error: %s\n\n", target, harvest_err.Error())
+			}
+			certificate_vhosts = harvested_vhosts
+		}
+		options.checkpoint.start(certificate_vhosts)
+	}
 
-		// ----| Insert row into table
-		add_row_to_table_err := sqlite_utils.AddRowToTable(database_interface, "enumerated_vhosts", db_row)
-		if add_row_to_table_err != nil {
-			return errors.New("An error occurred while adding row to enumerated vhosts db table || Error: " + add_row_to_table_err.Error())
+	enumerated_vhosts, target_processing_err := process_target(ctx, target, certificate_vhosts, options)
+	if target_processing_err != nil {
+		if ctx.Err() != nil {
+			target_processing_err = err_interrupted
+		}
+		fmt.Fprintf(options.console, "> An error occured while processing target: %s || Error: %s\n\n", target, target_processing_err.Error())
+
+		// ----| Store the hits found before the error, they are not confirmed
+		if len(enumerated_vhosts) != 0 {
+			fmt.Fprintf(options.console, "  > Adding %d vhost(s) found before the error to database\n\n", len(enumerated_vhosts))
+		}
+		if err := add_enumerated_vhosts_to_db(options.checkpoint.row.Scan_id, target, options.target_expression, enumerated_vhosts); err != nil {
+			fmt.Fprintf(options.console, "> An error occurred while adding enumerated vhosts on target: %s to the db. || Error: %s\n", target, err.Error())
 		}
+		return target_processing_err
 	}
 
-	// ----| Close database interface
-	db_close_err := sqlite_utils.Close_database_interface(database_interface)
-	if db_close_err != nil {
-		return db_close_err
+	if len(enumerated_vhosts) != 0 {
+		fmt.Fprintf(options.console, "  > Adding enumerated vhosts to database\n\n")
+	} else {
+		fmt.Fprint(options.console, "  > No vhosts were enumerated\n\n")
+	}
+	err := add_enumerated_vhosts_to_db(options.checkpoint.row.Scan_id, target, options.target_expression, enumerated_vhosts)
+	if err != nil {
+		fmt.Fprintf(options.console, "> An error occurred while adding enumerated vhosts on target: %s to the db. || Error: %s\n", target, err.Error())
+		return err
 	}
+	options.checkpoint.finish()
+
+	fmt.Fprintf(options.console, "  > Finished VHost Enumeration On Target: %s\n\n", target)
 	return nil
 }
 
-func run(targets_file_path_or_target_url string, vhosts_lists_path string, allow_insecure_requests bool) error {
+func run(ctx context.Context, options t_scan_options) error {
+
+	targets_file_path_or_target_url := options.targets_file_path_or_target_url
+	vhosts_lists_path := options.vhosts_lists_path
+
+	// ----| Parse fields used to compare candidates against the baseline
+	compare_fields, compare_fields_err := baseline_utils.Parse_compare_fields(options.compare_fields_list)
+	if compare_fields_err != nil {
+		return compare_fields_err
+	}
+	options.compare_fields = compare_fields
+
+	// ----| Compile match and filter rules
+	match_rule_set, match_rules_err := match_utils.Parse_rule_set(options.match_rules_input)
+	if match_rules_err != nil {
+		return errors.New("An error occurred while parsing match rules || Error: " + match_rules_err.Error())
+	}
+	filter_rule_set, filter_rules_err := match_utils.Parse_rule_set(options.filter_rules_input)
+	if filter_rules_err != nil {
+		return errors.New("An error occurred while parsing filter rules || Error: " + filter_rules_err.Error())
+	}
+	options.match_rules = match_utils.Rules{Match: match_rule_set, Filter: filter_rule_set}
+
+	// ----| Build response body normalizer
+	if options.disable_normalization == false {
+		normalizer, normalizer_err := normalize_utils.New_normalizer(options.normalization_rules_path)
+		if normalizer_err != nil {
+			return normalizer_err
+		}
+		options.probe_config.Normalizer = normalizer
+	}
+
+	// ----| Parse probe modes
+	probe_modes, probe_modes_err := request_utils.Parse_probe_modes(options.probe_modes_list, options.sni_front)
+	if probe_modes_err != nil {
+		return probe_modes_err
+	}
+	options.probe_modes = probe_modes
+	options.probe_config.Sni_front = options.sni_front
 
-	if allow_insecure_requests == true {
-		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true} // Configure http to allow insecure requests
+	// ----| Parse injection vectors
+	injection_vectors, injection_vectors_err := request_utils.Parse_injection_vectors(options.injection_vectors_list)
+	if injection_vectors_err != nil {
+		return injection_vectors_err
 	}
+	options.injection_vectors = injection_vectors
 
-	var targets_list []string
-	if input_utils.IsDomainOrURL(targets_file_path_or_target_url) == false { // Means targets_list_path is a file
-		// ----| Load targets from file
-		targets_from_file, file_read_err := file_utils.Read_lines(targets_file_path_or_target_url)
-		if file_read_err != nil {
-			return errors.New(fmt.Sprintf("An error occurred while attempting to read targets from file: %s || Error: %s", targets_file_path_or_target_url, file_read_err.Error()))
+	// ----| Load header profiles
+	if options.header_profiles_path != "" {
+		header_profiles, header_profiles_err := request_utils.Load_header_profiles(options.header_profiles_path)
+		if header_profiles_err != nil {
+			return header_profiles_err
 		}
-		targets_list = targets_from_file
-	} else { // Means targets_list_path is a url
-		targets_list = append(targets_list, targets_file_path_or_target_url)
+		options.header_profiles = header_profiles
+	}
+	switch options.header_profile_scope {
+	case request_utils.Header_profile_scope_scan:
+		header_profile := request_utils.Pick_header_profile(options.header_profiles)
+		options.probe_config.Header_profile = &header_profile
+		fmt.Printf("> Header profile: %s (User-Agent: %s)\n", header_profile.Name, header_profile.Headers.Get("User-Agent"))
+	case request_utils.Header_profile_scope_target:
+	default:
+		return errors.New("Unknown header profile scope: " + options.header_profile_scope + " (valid scopes: " + request_utils.Header_profile_scope_target + ", " + request_utils.Header_profile_scope_scan + ")")
+	}
+
+	// ----| Build the rate limiter shared by every worker of every target
+	jitter, jitter_err := schedule_utils.Parse_jitter(options.jitter_distribution, options.jitter_min, options.jitter_max)
+	if jitter_err != nil {
+		return jitter_err
+	}
+	options.probe_config.Rate_limiter = schedule_utils.New_rate_limiter(options.rate, options.rate_per_host, options.burst, jitter, schedule_utils.Backoff{Base: options.throttle_backoff, Max: options.throttle_max_backoff})
+
+	// ----| Parse status codes that mean a target is throttling us
+	throttle_status_codes, throttle_status_codes_err := request_utils.Parse_throttle_status_codes(options.throttle_status_codes_list)
+	if throttle_status_codes_err != nil {
+		return throttle_status_codes_err
+	}
+	options.probe_config.Throttle_status_codes = throttle_status_codes
+
+	// ----| Build the HTTP client shared by every target
+	http_client, http_client_err := request_utils.New_http_client(request_utils.Client_config{
+		Redirect_mode:           options.redirect_mode,
+		Allow_insecure_requests: options.allow_insecure_requests,
+		Connect_timeout:         options.connect_timeout,
+		Tls_timeout:             options.tls_timeout,
+		Total_timeout:           options.total_timeout,
+		Max_idle_conns:          options.max_idle_conns,
+		Max_idle_conns_per_host: options.max_idle_conns_per_host,
+		Idle_conn_timeout:       options.idle_conn_timeout,
+	})
+	if http_client_err != nil {
+		return http_client_err
 	}
+	options.probe_config.Client = http_client
 
-	// ----| Load vhosts from file
-	vhosts_list, file_read_err := file_utils.Read_lines(vhosts_lists_path)
+	// ----| When redirects are not blindly followed the redirect target is what tells vhosts apart
+	if options.redirect_mode != request_utils.Redirect_mode_follow && !slices.Contains(options.compare_fields, baseline_utils.Compare_field_location) {
+		options.compare_fields = append(options.compare_fields, baseline_utils.Compare_field_location)
+	}
+
+	// ----| Decide whether --targets is a file or a single target
+	is_targets_file, targets_argument_err := input_utils.Is_targets_file(targets_file_path_or_target_url)
+	if targets_argument_err != nil {
+		return targets_argument_err
+	}
+	schemes, schemes_err := input_utils.Parse_schemes(options.schemes_list)
+	if schemes_err != nil {
+		return schemes_err
+	}
+
+	// ----| Copy stdin to a temporary file, the vhosts list is read again for every target
+	if targets_file_path_or_target_url == file_utils.Stdin_path && vhosts_lists_path == file_utils.Stdin_path {
+		return errors.New("Only one of --targets and --vhosts can be read from stdin")
+	}
+	for _, input_path := range []*string{&targets_file_path_or_target_url, &vhosts_lists_path} {
+		if *input_path != file_utils.Stdin_path {
+			continue
+		}
+		spool_path, spool_err := file_utils.Spool_stdin()
+		if spool_err != nil {
+			return spool_err
+		}
+		defer os.Remove(spool_path)
+		*input_path = spool_path
+	}
+
+	// ----| Count targets, they are read one at a time when they are handed out to the scanners
+	targets_preview, targets_count, targets_filter, targets_read_err := preview_targets(targets_file_path_or_target_url, is_targets_file, schemes)
+	if targets_read_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while attempting to read targets from file: %s || Error: %s", targets_file_path_or_target_url, targets_read_err.Error()))
+	}
+
+	// ----| Count vhosts and collect their wildcard suffixes, they are read again for every target
+	wordlist, file_read_err := scan_wordlist(vhosts_lists_path)
 	if file_read_err != nil {
 		return errors.New(fmt.Sprintf("An error occurred while attempting to read vhosts from file: %s || Error: %s", vhosts_lists_path, file_read_err.Error()))
 	}
+	options.wordlist = wordlist
 
 	fmt.Println("▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁")
 
 	// ----| Print banner
-	banner_utils.Print_banner(targets_list, vhosts_list)
+	banner_utils.Print_banner(targets_preview, targets_count, wordlist.preview, wordlist.count)
+
+	// ----| Report the lines of the lists that will not be probed
+	if targets_summary := targets_filter.Summary(); targets_summary != "" {
+		fmt.Printf("> Dropped from targets: %s\n", targets_summary)
+	}
+	if vhosts_summary := wordlist.filter.Summary(); vhosts_summary != "" {
+		fmt.Printf("> Dropped from vhosts: %s\n", vhosts_summary)
+	}
 
 	fmt.Println("▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁")
 
+	// ----| Record the scan, or load the checkpoints of the scan being resumed
+	scan_id, scan_targets, start_scan_err := start_scan(options)
+	if start_scan_err != nil {
+		return start_scan_err
+	}
+	fmt.Printf("> Scan ID: %s (resume with --resume=%s)\n", scan_id, scan_id)
+
+	// ----| Scan targets in parallel, the requests of every target share the global and per host concurrency caps
+	options.probe_config.Concurrency_limiter = schedule_utils.New_concurrency_limiter(options.global_concurrency, options.per_host_concurrency)
+	parallel_targets := max(options.parallel_targets, 1)
+	targets_to_scan := make(chan t_target)
+
 	var targets_that_errored []t_target_that_encountered_error
-	for _, target := range targets_list {
+	var targets_that_errored_mutex sync.Mutex
+	var targets_skipped atomic.Int64 // Targets whose implied scheme nothing answers on
+	var scanners sync.WaitGroup
+	for range min(parallel_targets, targets_count) {
+		scanners.Go(func() {
+			for expanded_target := range targets_to_scan {
+				target := expanded_target.url
 
-		fmt.Printf("\n\n> Starting VHost Enumeration On: %s\n\n", target)
-		enumerated_vhosts, target_processing_err := process_target(target, vhosts_list)
-		if target_processing_err != nil {
-			fmt.Printf("> An error occured while processing target: %s || Error: %s", target, target_processing_err.Error())
-			targets_that_errored = append(targets_that_errored, t_target_that_encountered_error{target, target_processing_err})
-			continue
-		}
+				// ----| Targets in flight take turns on stdout, the output of waiting targets is printed as one block
+				console := new_console(true)
+				scan_target_row, is_resumed := scan_targets[target]
+				if scan_target_row.Status == sqlite_utils.Scan_target_status_done {
+					fmt.Fprintf(console, "\n\n> Skipping target finished before the scan was resumed: %s\n", target)
+					console.Flush()
+					continue
+				}
 
-		if len(enumerated_vhosts) != 0 {
-			fmt.Printf("  > Adding enumerated vhosts to database\n\n")
-			err := add_enumerated_vhosts_to_db(enumerated_vhosts)
-			if err != nil {
-				fmt.Printf("> An error occurred while adding enumerated vhosts on target: %s to the db. || Error: %s\n", target, err.Error())
-				targets_that_errored = append(targets_that_errored, t_target_that_encountered_error{target, err})
-				continue
+				target_options := options
+				target_options.console = console
+				target_options.target_expression = expanded_target.expression
+				target_options.implied_scheme = expanded_target.implied_scheme
+				checkpoint, checkpoint_err := new_checkpoint(scan_id, target, scan_target_row, is_resumed, console)
+				scan_err := checkpoint_err
+				if checkpoint_err == nil {
+					target_options.checkpoint = checkpoint
+					scan_err = scan_target(ctx, target, target_options)
+				}
+				console.Flush()
+				if scan_err == err_scheme_not_answering {
+					targets_skipped.Add(1)
+				} else if scan_err != nil {
+					targets_that_errored_mutex.Lock()
+					targets_that_errored = append(targets_that_errored, t_target_that_encountered_error{target, scan_err})
+					targets_that_errored_mutex.Unlock()
+				}
 			}
+		})
+	}
+	targets_stream, open_targets_err := open_targets(targets_file_path_or_target_url, is_targets_file, schemes)
+	if open_targets_err != nil {
+		return errors.New(fmt.Sprintf("An error occurred while attempting to read targets from file: %s || Error: %s", targets_file_path_or_target_url, open_targets_err.Error()))
+	}
+	defer targets_stream.close()
+	next_target := targets_stream.next
 
-		} else {
-			fmt.Println("  > No vhosts were enumerated\n")
+	var targets_not_scanned []string
+	targets_not_scanned_count := 0
+hand_out_targets:
+	for target, has_target := next_target(); has_target; target, has_target = next_target() {
+		select {
+		case targets_to_scan <- target:
+		case <-ctx.Done():
+			// ----| List the first targets that were not scanned and count the rest
+			for ; has_target; target, has_target = next_target() {
+				if len(targets_not_scanned) < banner_preview_size {
+					targets_not_scanned = append(targets_not_scanned, target.url)
+				}
+				targets_not_scanned_count++
+			}
+			break hand_out_targets
 		}
+	}
+	close(targets_to_scan)
+	scanners.Wait()
 
-		fmt.Printf("  > Finished VHost Enumeration On Target: %s\n\n", target)
-
-		sleep_time := rand.Intn(10) // n will be between 0 and 10
-		fmt.Printf("  > Sleeping %d seconds...\n", sleep_time)
-		time.Sleep(time.Duration(sleep_time) * time.Second)
+	// ----| A targets file that fails half way leaves the targets after the failure unscanned
+	if targets_stream.err() != nil {
+		targets_that_errored = append(targets_that_errored, t_target_that_encountered_error{targets_file_path_or_target_url, targets_stream.err()})
 	}
 
 	if len(targets_that_errored) != 0 {
@@ -193,7 +971,24 @@ func run(targets_file_path_or_target_url string, vhosts_lists_path string, allow
 		for _, target_that_encountered_error := range targets_that_errored {
 			fmt.Println("  > " + target_that_encountered_error.target + " || Error: " + target_that_encountered_error.error.Error())
 		}
-	} else {
+	}
+	if targets_skipped.Load() != 0 {
+		fmt.Printf("\n\n> Skipped %d target(s) given without scheme, nothing answers that scheme on their port\n", targets_skipped.Load())
+	}
+	if len(targets_not_scanned) != 0 {
+		fmt.Println("> Targets that were not scanned before the scan was interrupted")
+		for _, target_not_scanned := range targets_not_scanned {
+			fmt.Println("  > " + target_not_scanned)
+		}
+		if targets_not_scanned_count > len(targets_not_scanned) {
+			fmt.Printf("  > %d more targets\n", targets_not_scanned_count-len(targets_not_scanned))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return errors.New("Scan interrupted, the vhosts found so far were added to the database (resume with --resume=" + scan_id + ")")
+	}
+	if len(targets_that_errored) == 0 {
 		fmt.Println("\n\n> All targets were enumerated successfully")
 	}
 	return nil
@@ -201,14 +996,69 @@ func run(targets_file_path_or_target_url string, vhosts_lists_path string, allow
 
 func main() {
 	// Define flags
-	targets := flag.String("targets", "", "IP address or path to file containing target IPs")
-	vhosts := flag.String("vhosts", "", "Path to file containing vhosts for spoofing")
+	targets := flag.String("targets", "", "Target (URL, host, IP, IPv6 literal, CIDR block, IP range, optionally followed by :port or :port,port,...) or path to file (plain or gzip compressed, - for stdin) containing targets, an existing file always wins")
+	schemes := flag.String("schemes", "http,https", "Comma separated schemes targets given without one are scanned over (ports 80 and 443 imply http and https)")
+	vhosts := flag.String("vhosts", "", "Path to file (plain or gzip compressed, - for stdin) containing vhosts for spoofing, the file is streamed so it can be of any size")
+	shuffle_window := flag.Int("shuffle-window", 100000, "Number of vhosts held in memory to shuffle the vhosts list, candidates are shuffled within a window of this size as the list is streamed")
 	insecure := flag.Bool("insecure", false, "Allow insecure SSL/TLS connections")
+	baseline_samples := flag.Int("baseline-samples", 5, "Number of random Host header probes used to calibrate the baseline of each target")
+	similarity_threshold := flag.Float64("similarity-threshold", 0.1, "Simhash distance (0.0-1.0) from the baseline above which a response counts as a different vhost")
+	normalization_rules := flag.String("normalization-rules", "", "Path to file containing extra regex rules (one per line, optionally followed by ' => replacement') applied to response bodies before fingerprinting")
+	no_normalization := flag.Bool("no-normalization", false, "Fingerprint raw response bodies without replacing reflected Host values and volatile tokens")
+	compare := flag.String("compare", "body,status", "Comma separated response fields a candidate is compared to the baseline on ("+strings.Join(baseline_utils.All_compare_fields, ", ")+" or all)")
+	match_status := flag.String("match-status", "", "Only report hits with these status codes (e.g. 200,301-302 or all)")
+	match_size := flag.String("match-size", "", "Only report hits with these body sizes in bytes (e.g. 1234,2000-3000)")
+	match_words := flag.String("match-words", "", "Only report hits with these body word counts")
+	match_lines := flag.String("match-lines", "", "Only report hits with these body line counts")
+	match_regex := flag.String("match-regex", "", "Only report hits whose body matches this regex")
+	match_header_regex := flag.String("match-header-regex", "", "Only report hits whose headers (\"Name: value\" lines) match this regex")
+	filter_status := flag.String("filter-status", "", "Drop hits with these status codes")
+	filter_size := flag.String("filter-size", "", "Drop hits with these body sizes in bytes")
+	filter_words := flag.String("filter-words", "", "Drop hits with these body word counts")
+	filter_lines := flag.String("filter-lines", "", "Drop hits with these body line counts")
+	filter_regex := flag.String("filter-regex", "", "Drop hits whose body matches this regex (e.g. 'Default Backend')")
+	filter_header_regex := flag.String("filter-header-regex", "", "Drop hits whose headers (\"Name: value\" lines) match this regex")
+	redirects := flag.String("redirects", request_utils.Redirect_mode_follow, "How redirects are handled: follow (any host), none (fingerprint the redirect itself) or same-host (only follow redirects that stay on the probed vhost)")
+	confirmations := flag.Int("confirmations", 2, "Number of times each hit is re-probed (with a fresh baseline probe in between) before it is kept, 0 disables confirmation")
+	min_confidence := flag.Float64("min-confidence", 1.0, "Fraction (0.0-1.0) of confirmation rounds a hit must pass to be kept")
+	wildcard_samples := flag.Int("wildcard-samples", 3, "Number of random labels probed under each suffix in the vhost list to detect wildcard vhosts, 0 disables wildcard detection")
+	wildcard_min_candidates := flag.Int("wildcard-min-candidates", 2, "Only check suffixes shared by at least this many candidates for wildcards")
+	wildcard_max_suffixes := flag.Int("wildcard-max-suffixes", 25, "Maximum number of suffixes checked for wildcards per target (the ones shared by the most candidates), 0 disables the cap")
+	probe_modes := flag.String("probe-modes", request_utils.Probe_mode_host, "Comma separated places to put the candidate vhost: host (Host header), sni (TLS SNI), both, mismatch (SNI set to --sni-front, Host header set to the candidate)")
+	sni_front := flag.String("sni-front", "", "SNI sent in the mismatch probe mode")
+	no_certificate_harvesting := flag.Bool("no-cert-harvest", false, "Do not add the CN and subjectAltName entries of https targets' certificates to their candidates")
+	injection_vectors := flag.String("vectors", request_utils.Injection_vector_host, "Comma separated ways to inject the candidate into requests ("+strings.Join(request_utils.Injection_vectors, ", ")+" or all)")
+	header_profiles := flag.String("header-profiles", "", "Path to file containing request header profiles (\"[name]\" lines followed by \"Header: value\" lines), random profiles are generated when not set")
+	header_profile_scope := flag.String("header-profile-scope", request_utils.Header_profile_scope_target, "How often a header profile is chosen: target (once per target) or scan (once for the whole scan)")
+	concurrency := flag.Int("concurrency", 10, "Number of candidates probed concurrently on each target")
+	parallel_targets := flag.Int("parallel-targets", 4, "Number of targets scanned in parallel, the output of each target is printed as one block once it is done when > 1")
+	global_concurrency := flag.Int("global-concurrency", 40, "Maximum number of requests in flight across all targets, 0 disables the limit")
+	per_host_concurrency := flag.Int("per-host-concurrency", 10, "Maximum number of requests in flight to a single host, 0 disables the limit")
+	rate := flag.Float64("rate", 0, "Maximum number of requests per second across all targets, 0 disables the limit")
+	rate_per_host := flag.Float64("rate-per-host", 0, "Maximum number of requests per second to a single host, 0 disables the limit")
+	burst := flag.Int("burst", 1, "Number of requests that may be sent back to back before --rate and --rate-per-host apply")
+	jitter := flag.String("jitter", schedule_utils.Jitter_distribution_uniform, "Distribution of the random delay added before every request ("+strings.Join(schedule_utils.Jitter_distributions, ", ")+")")
+	jitter_min := flag.Duration("jitter-min", 0, "Smallest random delay added before every request")
+	jitter_max := flag.Duration("jitter-max", 2*time.Second, "Largest random delay added before every request")
+	throttle_status := flag.String("throttle-status", "429,503", "Comma separated status codes that mean a target is throttling us (error responses with a Retry-After header always do, a 503 without one only when the target's baseline does not answer 503)")
+	throttle_backoff := flag.Duration("throttle-backoff", 5*time.Second, "How long a throttling target is left alone when it sends no Retry-After header, doubled on every consecutive backoff")
+	throttle_max_backoff := flag.Duration("throttle-max-backoff", 5*time.Minute, "Longest backoff used when a throttling target sends no Retry-After header")
+	throttle_retries := flag.Int("throttle-retries", 3, "Number of times a throttled probe is retried before the candidate is given up on")
+	connect_timeout := flag.Duration("connect-timeout", 10*time.Second, "Timeout for establishing a TCP connection to a target")
+	tls_timeout := flag.Duration("tls-timeout", 10*time.Second, "Timeout for the TLS handshake with an https target")
+	total_timeout := flag.Duration("timeout", 30*time.Second, "Timeout for a whole request, from connecting to reading the last byte of the response (redirects included)")
+	max_idle_conns := flag.Int("max-idle-conns", 100, "Number of idle keep-alive connections kept open across all targets")
+	max_idle_conns_per_host := flag.Int("max-idle-conns-per-host", 10, "Number of idle keep-alive connections kept open per target")
+	idle_conn_timeout := flag.Duration("idle-conn-timeout", 90*time.Second, "How long an idle keep-alive connection is kept open")
+	retries := flag.Int("retries", 2, "Number of times a probe that failed with a timeout, connection reset or refused connection is retried")
+	retry_backoff := flag.Duration("retry-backoff", time.Second, "Delay before the first retry of a failed probe, doubled on every retry")
+	max_consecutive_errors := flag.Int("max-consecutive-errors", 10, "Number of candidates in a row that may fail (after retries) before a target is abandoned, the hits found so far are kept")
+	resume := flag.String("resume", "", "ID of an interrupted scan to resume, the scan's arguments are reused (arguments given here override them) and completed candidates are not probed again")
 
 	// Custom usage message
 	flag.Usage = func() {
 		fmt.Print(banner_utils.Cat + "\n")
-		fmt.Println("▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁\n\n")
+		fmt.Print("▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁▁\n\n\n")
 		fmt.Print("vhost-scout probes one or more targets to discover virtual-host (vhost) names.\n\n")
 		fmt.Printf("Usage: %s [options]\n\n", os.Args[0])
 		fmt.Println("Options:")
@@ -216,10 +1066,23 @@ func main() {
 		fmt.Println("\nExample:")
 		fmt.Printf("  %s --targets=192.168.1.1 --vhosts=wordlist.txt --insecure\n", os.Args[0])
 		fmt.Printf("  %s --targets=targets.txt --vhosts=vhosts.txt\n", os.Args[0])
+		fmt.Printf("  %s --targets=targets.txt --vhosts=vhosts.txt --match-status=200,301 --filter-regex='Default Backend'\n", os.Args[0])
 	}
 
 	flag.Parse()
 
+	// ----| Resume with the arguments the scan was started with, arguments given on the command line take precedence
+	arguments := os.Args[1:]
+	if *resume != "" {
+		resumed_arguments, load_arguments_err := load_resumed_arguments(*resume)
+		if load_arguments_err != nil {
+			fmt.Printf("Error: %v\n", load_arguments_err)
+			os.Exit(1)
+		}
+		flag.CommandLine.Parse(append(resumed_arguments, os.Args[1:]...))
+		arguments = resumed_arguments
+	}
+
 	// Validate required flags
 	if *targets == "" || *vhosts == "" {
 		fmt.Println("Error: --targets and --vhosts are required")
@@ -227,7 +1090,85 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := run(*targets, *vhosts, *insecure); err != nil {
+	options := t_scan_options{
+		targets_file_path_or_target_url: *targets,
+		schemes_list:                    *schemes,
+		vhosts_lists_path:               *vhosts,
+		shuffle_window:                  *shuffle_window,
+		allow_insecure_requests:         *insecure,
+		baseline_samples:                *baseline_samples,
+		similarity_threshold:            *similarity_threshold,
+		normalization_rules_path:        *normalization_rules,
+		disable_normalization:           *no_normalization,
+		compare_fields_list:             *compare,
+		redirect_mode:                   *redirects,
+		confirmations:                   *confirmations,
+		min_confidence:                  *min_confidence,
+		wildcard_samples:                *wildcard_samples,
+		wildcard_min_candidates:         *wildcard_min_candidates,
+		wildcard_max_suffixes:           *wildcard_max_suffixes,
+		probe_modes_list:                *probe_modes,
+		sni_front:                       *sni_front,
+		disable_certificate_harvesting:  *no_certificate_harvesting,
+		injection_vectors_list:          *injection_vectors,
+		header_profiles_path:            *header_profiles,
+		header_profile_scope:            *header_profile_scope,
+		concurrency:                     *concurrency,
+		parallel_targets:                *parallel_targets,
+		global_concurrency:              *global_concurrency,
+		per_host_concurrency:            *per_host_concurrency,
+		rate:                            *rate,
+		rate_per_host:                   *rate_per_host,
+		burst:                           *burst,
+		jitter_distribution:             *jitter,
+		jitter_min:                      *jitter_min,
+		jitter_max:                      *jitter_max,
+		throttle_status_codes_list:      *throttle_status,
+		throttle_backoff:                *throttle_backoff,
+		throttle_max_backoff:            *throttle_max_backoff,
+		throttle_retries:                *throttle_retries,
+		connect_timeout:                 *connect_timeout,
+		tls_timeout:                     *tls_timeout,
+		total_timeout:                   *total_timeout,
+		max_idle_conns:                  *max_idle_conns,
+		max_idle_conns_per_host:         *max_idle_conns_per_host,
+		idle_conn_timeout:               *idle_conn_timeout,
+		retries:                         *retries,
+		retry_backoff:                   *retry_backoff,
+		max_consecutive_errors:          *max_consecutive_errors,
+		arguments:                       arguments,
+		resume_scan_id:                  *resume,
+		match_rules_input: match_utils.Rule_set_input{
+			Status:       *match_status,
+			Size:         *match_size,
+			Words:        *match_words,
+			Lines:        *match_lines,
+			Body_regex:   *match_regex,
+			Header_regex: *match_header_regex,
+		},
+		filter_rules_input: match_utils.Rule_set_input{
+			Status:       *filter_status,
+			Size:         *filter_size,
+			Words:        *filter_words,
+			Lines:        *filter_lines,
+			Body_regex:   *filter_regex,
+			Header_regex: *filter_header_regex,
+		},
+	}
+
+	// ----| Stop sending new requests on SIGINT/SIGTERM, a second signal quits right away
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt_signals := make(chan os.Signal, 1)
+	signal.Notify(interrupt_signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt_signals
+		signal.Stop(interrupt_signals)
+		fmt.Fprint(new_console(false), "\n\n> Interrupted, finishing in-flight requests and saving the vhosts found so far (interrupt again to quit immediately)\n\n")
+		cancel()
+	}()
+
+	if err := run(ctx, options); err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
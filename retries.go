@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"vhost-scout/include/request_utils"
+	"vhost-scout/include/schedule_utils"
+)
+
+// t_error_budget counts the candidates of a target that failed one after another. A target is abandoned once the
+// count reaches the budget, a single successful probe resets it.
+type t_error_budget struct {
+	budget             int64
+	consecutive_errors atomic.Int64
+}
+
+func new_error_budget(budget int) *t_error_budget {
+	return &t_error_budget{budget: int64(max(budget, 1))}
+}
+
+func (error_budget *t_error_budget) record_success() {
+	error_budget.consecutive_errors.Store(0)
+}
+
+// record_error counts a failed candidate and reports whether the budget is spent.
+func (error_budget *t_error_budget) record_error() bool {
+	return error_budget.consecutive_errors.Add(1) >= error_budget.budget
+}
+
+// error_class returns the Error_class_* of a probe error.
+func error_class(err error) string {
+	var request_err *request_utils.Request_error
+	if errors.As(err, &request_err) {
+		return request_err.Class
+	}
+	return request_utils.Error_class_other
+}
+
+// root_cause returns the last line of an error message, which is where the errors of the request layer put the
+// underlying network error.
+func root_cause(err error) string {
+	message := strings.TrimSpace(err.Error())
+	return message[strings.LastIndex(message, "\n")+1:]
+}
+
+// send_with_retries sends a probe and sends it again after an exponentially growing delay (options.retry_backoff,
+// doubled every attempt) when it fails with a transient network error, up to options.retries times.
+func send_with_retries(ctx context.Context, target string, vhost string, options t_scan_options) (request_utils.ResponseFingerprint, error) {
+	for retry := 0; ; retry++ {
+		fingerprint, probe_err := request_utils.Send_request_with_spoofed_host_header(ctx, target, vhost, options.probe_config)
+		if probe_err == nil || ctx.Err() != nil || retry >= options.retries || !request_utils.Is_transient(error_class(probe_err)) {
+			return fingerprint, probe_err
+		}
+
+		retry_delay := options.retry_backoff << retry
+		fmt.Fprintf(options.console, "  > Retrying: %s in %s (%s error, attempt %d/%d)\n\n", vhost, retry_delay, error_class(probe_err), retry+1, options.retries)
+		if sleep_err := schedule_utils.Sleep(ctx, retry_delay); sleep_err != nil {
+			return fingerprint, sleep_err
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"vhost-scout/include/file_utils"
+	"vhost-scout/include/input_utils"
+	"vhost-scout/include/random_utils"
+	"vhost-scout/include/request_utils"
+)
+
+// t_target is a URL to scan and the line of the targets list it was expanded from.
+type t_target struct {
+	url            string
+	expression     string // Normalized targets list line (host, CIDR block, IP range, port list, ...)
+	implied_scheme bool   // The line gave no scheme and the URL is one of several scanned for it, one per scheme
+}
+
+// t_target_stream reads the targets to scan lazily: the lines of a targets file, or the single target given on the
+// command line, normalized and expanded to the URLs to scan. Ranges are expanded one address at a time, a URL that
+// overlapping lines expand to is scanned once, for the first of them, as long as it is one of the last
+// input_utils.Dedupe_window URLs handed out.
+type t_target_stream struct {
+	lines           *file_utils.Line_stream // Nil for a single target
+	filter          *input_utils.Line_filter
+	next_expression func() (string, bool)
+	expression      string
+	next_expanded   func() (string, bool) // Targets of expression, nil before the first line is read
+	pending         []string
+	pending_implied bool                      // The pending URLs are the schemes a target given without one is scanned over
+	handed_out      *input_utils.Recent_lines // Last URLs handed out
+	schemes         []string
+}
+
+// open_targets opens the targets stream of the --targets argument.
+func open_targets(targets_argument string, is_targets_file bool, schemes []string) (*t_target_stream, error) {
+
+	target_stream := &t_target_stream{filter: input_utils.New_target_filter(), handed_out: input_utils.New_recent_lines(input_utils.Dedupe_window), schemes: schemes}
+	if !is_targets_file {
+		target_handed_out := false
+		target_stream.next_expression = func() (string, bool) {
+			if target_handed_out {
+				return "", false
+			}
+			target_handed_out = true
+			return target_stream.filter.Filter(targets_argument)
+		}
+		return target_stream, nil
+	}
+
+	lines, open_err := file_utils.Open_line_stream(targets_argument)
+	if open_err != nil {
+		return nil, open_err
+	}
+	target_stream.lines = lines
+	target_stream.next_expression = filtered_lines(lines, target_stream.filter)
+	return target_stream, nil
+}
+
+// next returns the next target to scan, false once the stream is exhausted or failed (see err).
+func (target_stream *t_target_stream) next() (t_target, bool) {
+	for {
+		for len(target_stream.pending) != 0 {
+			target_url := target_stream.pending[0]
+			target_stream.pending = target_stream.pending[1:]
+			if target_stream.handed_out.Add(target_url) {
+				return t_target{url: target_url, expression: target_stream.expression, implied_scheme: target_stream.pending_implied}, true
+			}
+		}
+		if target_stream.next_expanded != nil {
+			if target, has_target := target_stream.next_expanded(); has_target {
+				target_stream.pending = input_utils.Target_urls(target, target_stream.schemes)
+				target_stream.pending_implied = len(target_stream.pending) > 1
+				continue
+			}
+		}
+		expression, has_expression := target_stream.next_expression()
+		if !has_expression {
+			return t_target{}, false
+		}
+
+		// ----| Lines are normalized by the filter, which only keeps lines that expand
+		next_expanded, expand_err := input_utils.Expand_target(expression)
+		if expand_err != nil {
+			continue
+		}
+		target_stream.expression = expression
+		target_stream.next_expanded = next_expanded
+	}
+}
+
+func (target_stream *t_target_stream) err() error {
+	if target_stream.lines == nil {
+		return nil
+	}
+	return target_stream.lines.Err()
+}
+
+func (target_stream *t_target_stream) close() {
+	if target_stream.lines != nil {
+		target_stream.lines.Close()
+	}
+}
+
+// check_scheme_answers sends a single request to target and returns its error when the connection was refused or the
+// port does not speak TLS. Any response, and any other error, counts as an answer, the scan then fails or succeeds as
+// usual. An http request to a TLS port gets a response (400 from most servers) and is scanned.
+func check_scheme_answers(ctx context.Context, target string, options t_scan_options) error {
+	_, request_err := request_utils.Send_request_with_spoofed_host_header(ctx, target, random_utils.Gen_random_host(0), options.probe_config)
+	if request_err == nil || as_throttled_error(request_err) != nil {
+		return nil
+	}
+	if error_class(request_err) == request_utils.Error_class_refused || request_utils.Is_scheme_mismatch(request_err) {
+		return request_err
+	}
+	return nil
+}
+
+// preview_targets reads the targets once and returns the first URLs to scan, the number of URLs and what was dropped.
+func preview_targets(targets_argument string, is_targets_file bool, schemes []string) ([]string, int, *input_utils.Line_filter, error) {
+
+	target_stream, open_err := open_targets(targets_argument, is_targets_file, schemes)
+	if open_err != nil {
+		return nil, 0, nil, open_err
+	}
+	defer target_stream.close()
+
+	var preview []string
+	count := 0
+	for target, has_target := target_stream.next(); has_target; target, has_target = target_stream.next() {
+		if len(preview) < banner_preview_size {
+			preview = append(preview, target.url)
+		}
+		count++
+	}
+	return preview, count, target_stream.filter, target_stream.err()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"vhost-scout/include/request_utils"
+)
+
+// log_backoff prints a backoff event to the target's console. Only the response that paused the host is printed,
+// throttling responses to requests that were already in flight are not.
+func log_backoff(throttled_err *request_utils.Throttled_error, options t_scan_options) {
+	if !throttled_err.Started_backoff {
+		return
+	}
+
+	retry_after := "none"
+	if throttled_err.Retry_after > 0 {
+		retry_after = throttled_err.Retry_after.String()
+	}
+	fmt.Fprintf(options.console, "  > Throttled on: %s %s", throttled_err.Vhost, color.YellowString("(Status Code: %d, Retry-After: %s, Backing off for: %s)\n\n", throttled_err.Status_code, retry_after, throttled_err.Backoff))
+}
+
+// as_throttled_error returns the Throttled_error wrapped in err, or nil when the target did not throttle the request.
+func as_throttled_error(err error) *request_utils.Throttled_error {
+	var throttled_err *request_utils.Throttled_error
+	if errors.As(err, &throttled_err) {
+		return throttled_err
+	}
+	return nil
+}
+
+// send_probe sends a probe whose result is needed right away (baseline and confirmation probes). Throttled probes
+// are sent again once the rate limiter lets requests to the target through again, up to options.throttle_retries
+// times.
+func send_probe(ctx context.Context, target string, vhost string, options t_scan_options) (request_utils.ResponseFingerprint, error) {
+	for retry := 0; ; retry++ {
+		fingerprint, probe_err := send_with_retries(ctx, target, vhost, options)
+		throttled_err := as_throttled_error(probe_err)
+		if throttled_err == nil || ctx.Err() != nil {
+			return fingerprint, probe_err
+		}
+		log_backoff(throttled_err, options)
+		if retry >= options.throttle_retries {
+			return fingerprint, probe_err
+		}
+	}
+}
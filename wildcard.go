@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// max_tracked_wildcard_suffixes bounds the number of distinct suffixes counted for a vhosts list, suffixes first seen
+// once that many are counted are left out.
+const max_tracked_wildcard_suffixes = 100000
+
+// t_wildcard_suffixes counts the candidates under every suffix with at least two labels (a.corp.example.com ->
+// corp.example.com, example.com), so only suffixes shared by several candidates are checked for wildcards.
+type t_wildcard_suffixes struct {
+	counts    map[string]int
+	untracked int // Candidates under a suffix that was not counted
+}
+
+func new_wildcard_suffixes() *t_wildcard_suffixes {
+	return &t_wildcard_suffixes{counts: map[string]int{}}
+}
+
+// add counts vhost under every suffix it sits under.
+func (wildcard_suffixes *t_wildcard_suffixes) add(vhost string) {
+	labels := strings.Split(strings.Trim(strings.ToLower(vhost), "."), ".")
+	for i := 1; i < len(labels)-1; i++ {
+		suffix := strings.Join(labels[i:], ".")
+		if _, is_tracked := wildcard_suffixes.counts[suffix]; !is_tracked && len(wildcard_suffixes.counts) >= max_tracked_wildcard_suffixes {
+			wildcard_suffixes.untracked++
+			continue
+		}
+		wildcard_suffixes.counts[suffix]++
+	}
+}
+
+func (wildcard_suffixes *t_wildcard_suffixes) clone() *t_wildcard_suffixes {
+	return &t_wildcard_suffixes{counts: maps.Clone(wildcard_suffixes.counts), untracked: wildcard_suffixes.untracked}
+}
+
+// selected returns the suffixes worth checking, the max_suffixes shared by the most candidates among those shared by
+// at least min_candidates, ordered from the least to the most specific. It also returns how many suffixes were left
+// out for being shared by too few candidates and for being over max_suffixes (<= 0 disables the cap).
+func (wildcard_suffixes *t_wildcard_suffixes) selected(min_candidates int, max_suffixes int) ([]string, int, int) {
+
+	var suffixes []string
+	too_rare := 0
+	for suffix, count := range wildcard_suffixes.counts {
+		if count < min_candidates {
+			too_rare++
+			continue
+		}
+		suffixes = append(suffixes, suffix)
+	}
+
+	// ----| Keep the most shared suffixes
+	over_cap := 0
+	if max_suffixes > 0 && len(suffixes) > max_suffixes {
+		sort.Slice(suffixes, func(i, j int) bool {
+			i_count, j_count := wildcard_suffixes.counts[suffixes[i]], wildcard_suffixes.counts[suffixes[j]]
+			if i_count != j_count {
+				return i_count > j_count
+			}
+			return less_specific(suffixes[i], suffixes[j])
+		})
+		over_cap = len(suffixes) - max_suffixes
+		suffixes = suffixes[:max_suffixes]
+	}
+
+	sort.Slice(suffixes, func(i, j int) bool { return less_specific(suffixes[i], suffixes[j]) })
+	return suffixes, too_rare, over_cap
+}
+
+// less_specific orders suffixes by number of labels, then alphabetically.
+func less_specific(suffix string, other_suffix string) bool {
+	suffix_labels, other_suffix_labels := strings.Count(suffix, "."), strings.Count(other_suffix, ".")
+	if suffix_labels != other_suffix_labels {
+		return suffix_labels < other_suffix_labels
+	}
+	return suffix < other_suffix
+}
+
+// select_baseline returns the baseline of the most specific wildcard suffix vhost sits under, or the generic baseline.
+func select_baseline(vhost string, generic_baseline t_baseline, wildcard_baselines map[string]t_baseline) t_baseline {
+
+	selected_baseline := generic_baseline
+	vhost = strings.Trim(strings.ToLower(vhost), ".")
+	for suffix, wildcard_baseline := range wildcard_baselines {
+		if strings.HasSuffix(vhost, "."+suffix) && len(suffix) > len(selected_baseline.wildcard_suffix) {
+			selected_baseline = wildcard_baseline
+		}
+	}
+	return selected_baseline
+}
+
+// detect_wildcards probes random labels under every suffix of the target's candidates. A suffix is a wildcard when every one of
+// its random labels differs from the baseline its names would otherwise be compared against (the generic baseline or
+// the baseline of a less specific wildcard). The returned baselines are used for candidates under those suffixes and
+// every detected wildcard is also returned as a finding of its own.
+func detect_wildcards(ctx context.Context, target string, suffixes []string, generic_baseline t_baseline, options t_scan_options) (map[string]t_baseline, []t_vhost) {
+
+	wildcard_baselines := map[string]t_baseline{}
+	var wildcard_findings []t_vhost
+
+	if len(suffixes) == 0 {
+		return wildcard_baselines, nil
+	}
+	fmt.Fprintf(options.console, "  > Checking %d suffix(es) for wildcard vhosts\n\n", len(suffixes))
+
+	for _, suffix := range suffixes {
+
+		// ----| Probe random labels under suffix
+		suffix_baseline, calibration_err := calibrate_baseline(ctx, target, suffix, options.wildcard_samples, options)
+		if ctx.Err() != nil {
+			break
+		}
+		if calibration_err != nil {
+			fmt.Fprintf(options.console, "  > Skipping wildcard check for: *.%s || Error: %s\n\n", suffix, calibration_err.Error())
+			continue
+		}
+
+		// ----| Compare against the baseline names under suffix would otherwise use
+		parent_baseline := select_baseline(suffix_baseline.model.Samples[0].Vhost, generic_baseline, wildcard_baselines)
+		is_wildcard := true
+		var differing_fields []string
+		similarity_distance := 0.0
+		for _, sample := range suffix_baseline.model.Samples {
+			if !is_hit(parent_baseline.model, sample.Fingerprint, options) {
+				is_wildcard = false
+				break
+			}
+			differing_fields, similarity_distance = parent_baseline.model.Is_outside_model(sample.Fingerprint, options.compare_fields)
+		}
+		if !is_wildcard {
+			continue
+		}
+
+		suffix_baseline.drop_raw_responses()
+		wildcard_baselines[suffix] = suffix_baseline
+		wildcard_fingerprint := suffix_baseline.model.Samples[0].Fingerprint
+		wildcard_finding := t_vhost{
+			target:                      target,
+			vhost:                       "*." + suffix,
+			baseline_response_body_md5:  parent_baseline.model.Representative_md5(),
+			spoofed_response_body_md5:   suffix_baseline.model.Representative_md5(),
+			spoofed_request_status_code: wildcard_fingerprint.Status_code,
+			similarity_distance:         similarity_distance,
+			fingerprint:                 wildcard_fingerprint,
+			differing_fields:            differing_fields,
+			wildcard_suffix:             parent_baseline.wildcard_suffix,
+			finding_type:                finding_type_wildcard,
+			probe_mode:                  options.probe_config.Probe_mode,
+			injection_vector:            injection_vector_label(options.probe_config.Injection_vector),
+		}
+		fmt.Fprintf(options.console, "  > Wildcard: %s %s", wildcard_finding.vhost, color.MagentaString("(Status Code: %d, %s)\n\n", wildcard_fingerprint.Status_code, suffix_baseline.model.Describe()))
+		wildcard_findings = append(wildcard_findings, wildcard_finding)
+	}
+	return wildcard_baselines, wildcard_findings
+}